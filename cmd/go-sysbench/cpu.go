@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/samitani/go-sysbench"
+)
+
+const NameCPU = "cpu"
+
+type (
+	CPUOpts struct {
+		CPUMaxPrime int `long:"cpu-max-prime" description:"upper limit for primes generator" default:"10000"`
+	}
+
+	// CPUBench is equivalent to sysbench cpu. Each event calculates prime numbers up to --cpu-max-prime.
+	// https://github.com/akopytov/sysbench/blob/1.0.20/src/tests/cpu/sb_cpu.c
+	CPUBench struct {
+		opts *CPUOpts
+	}
+)
+
+func newCPUBench(option *CPUOpts) *CPUBench {
+	return &CPUBench{opts: option}
+}
+
+func (c *CPUBench) Init(ctx context.Context) error {
+	if c.opts.CPUMaxPrime <= 0 {
+		return fmt.Errorf("Invalid value for cpu-max-prime: %d", c.opts.CPUMaxPrime)
+	}
+	return nil
+}
+
+func (c *CPUBench) Done() error {
+	return nil
+}
+
+func (c *CPUBench) Prepare(ctx context.Context) error {
+	return fmt.Errorf("'%s' test does not implement the 'prepare' command", NameCPU)
+}
+
+func (c *CPUBench) PreEvent(ctx context.Context) error {
+	fmt.Printf("Prime numbers limit: %d\n\n", c.opts.CPUMaxPrime)
+	return nil
+}
+
+func (c *CPUBench) Event(ctx context.Context) (numReads, numWrites, numOthers, numIgnoredErros uint64, err error) {
+	countPrimes(c.opts.CPUMaxPrime)
+	return 0, 0, 0, 0, nil
+}
+
+// Summary prints events per second in the same format as sysbench cpu.
+func (c *CPUBench) Summary(result *sysbench.Result) {
+	fmt.Printf("CPU speed:\n"+
+		"    events per second: %8.2f\n\n", float64(result.TotalEvents)/result.TotalTime.Seconds())
+}
+
+// countPrimes counts prime numbers below maxPrime by trial division, in the same way as sysbench.
+func countPrimes(maxPrime int) int {
+	var n int
+
+	for c := 3; c < maxPrime; c++ {
+		t := int(math.Sqrt(float64(c)))
+		l := 2
+		for ; l <= t; l++ {
+			if c%l == 0 {
+				break
+			}
+		}
+		if l > t {
+			n++
+		}
+	}
+	return n
+}
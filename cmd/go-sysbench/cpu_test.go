@@ -0,0 +1,15 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCountPrimes(t *testing.T) {
+	// 2 is not counted, as in sysbench
+	expected := map[int]int{3: 0, 4: 1, 100: 24, 10000: 1228}
+	for maxPrime, count := range expected {
+		if actual := countPrimes(maxPrime); actual != count {
+			t.Errorf("Expected %d primes below %d, got %d", count, maxPrime, actual)
+		}
+	}
+}
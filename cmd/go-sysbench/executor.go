@@ -0,0 +1,428 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+
+	"github.com/googleapis/go-sql-spanner"
+
+	"github.com/samitani/go-sysbench"
+)
+
+type (
+	// stmtExecutor runs the statements of an event on a host by name.
+	// Statements run in autocommit until begin is called, and in the transaction until commit or rollback.
+	stmtExecutor interface {
+		begin(ctx context.Context, txOpt *sql.TxOptions) error
+		commit(ctx context.Context) error
+		// rollback rolls back the transaction if it has begun.
+		rollback()
+		query(ctx context.Context, tableNum int, stmtName string, args ...any) (*sql.Rows, error)
+		exec(ctx context.Context, tableNum int, stmtName string, args ...any) (sql.Result, error)
+		// release returns the connection held for the event.
+		release()
+	}
+
+	// sqlRunner is *sql.DB, *sql.Conn or *sql.Tx.
+	sqlRunner interface {
+		QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+		ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	}
+
+	// staticExecutor sends the statement text each time with --db-ps-mode=disable.
+	// Drivers bind arguments on client side, or in an unnamed prepared statement.
+	staticExecutor struct {
+		o    *OLTPBench
+		db   *sql.DB
+		conn *sql.Conn // Spanner single-use reads run on a connection with the staleness
+		tx   *sql.Tx
+	}
+
+	// preparedExecutor runs *sql.Stmt prepared on the connection pool with --db-ps-mode=auto.
+	// database/sql prepares the statement again on each connection it runs on for the first time.
+	preparedExecutor struct {
+		o  *OLTPBench
+		h  *dbHost
+		tx *sql.Tx
+	}
+
+	// connExecutor runs statements prepared once on the connection of the thread with --db-ps-mode=server, as sysbench does.
+	// *sql.Tx prepares statements again in the transaction, so BEGIN and COMMIT are sent as statements on the connection.
+	connExecutor struct {
+		o        *OLTPBench
+		h        *dbHost
+		threadID int
+		tc       *threadConn
+		inTx     bool
+	}
+
+	// threadConn is the connection dedicated to a thread and the statements prepared on it.
+	threadConn struct {
+		conn  *sql.Conn
+		stmts map[int]map[string]*sql.Stmt // tableNum -> stmtName -> preparedStmt
+	}
+)
+
+// useTx returns whether an event runs its statements in a transaction.
+// They run in autocommit with --skip-trx, and as single-use reads with --spanner-read-mode=single-use.
+func (o *OLTPBench) useTx() bool {
+	return o.opts.SkipTrx == OptOff && !o.spannerSingleUse()
+}
+
+func (o *OLTPBench) spannerSingleUse() bool {
+	return o.opts.DBDriver == DBDriverSpanner && o.opts.SpannerReadMode == OptSpannerReadModeSingleUse
+}
+
+// formatStmts builds the statements of each table from the templates.
+func (o *OLTPBench) formatStmts(stmtTemplates map[string]string) map[int]map[string]string {
+	stmts := make(map[int]map[string]string)
+	for tableNum := 1; tableNum <= o.opts.Tables; tableNum++ {
+		stmts[tableNum] = make(map[string]string)
+		for stmtName, stmtString := range stmtTemplates {
+			stmts[tableNum][stmtName] = o.formatStmt(stmtString, tableNum)
+		}
+	}
+	return stmts
+}
+
+// prepareStmts sets the statements of each table from 1 which executors run, and prepares them on the hosts with --db-ps-mode=auto.
+// Replicas may reject writes even in PREPARE, so only readStmts are prepared on them.
+func (o *OLTPBench) prepareStmts(ctx context.Context, stmts map[int]map[string]string, readStmts []string) error {
+	var err error
+
+	o.staticStmts = stmts
+
+	for _, h := range o.hosts {
+		h.stmtNames = nil
+		// all the tables have the same statements
+		for stmtName := range stmts[1] {
+			h.stmtNames = append(h.stmtNames, stmtName)
+		}
+	}
+	for _, r := range o.readHosts {
+		r.stmtNames = readStmts
+	}
+
+	// single-use reads run on a connection with the staleness, where *sql.Stmt can not be used.
+	// go-sql-spanner parses statements on client side, so prepared statements make no difference.
+	if o.opts.DBPreparedStmt != OptDBPreparedStmtAuto || o.spannerSingleUse() {
+		return nil
+	}
+
+	for _, hosts := range [][]*dbHost{o.hosts, o.readHosts} {
+		for _, h := range hosts {
+			h.preparedStmts = make(map[int]map[string]*sql.Stmt)
+			for tableNum := range stmts {
+				h.preparedStmts[tableNum] = make(map[string]*sql.Stmt)
+				for _, stmtName := range h.stmtNames {
+					h.preparedStmts[tableNum][stmtName], err = h.db.PrepareContext(ctx, o.staticStmts[tableNum][stmtName])
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// executorFor returns the executor which runs statements of the event on the host.
+func (o *OLTPBench) executorFor(ctx context.Context, h *dbHost) (stmtExecutor, error) {
+	if o.spannerSingleUse() {
+		conn, err := h.db.Conn(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		// staleness is reset when the connection is returned to the pool
+		err = conn.Raw(func(driverConn any) error {
+			return driverConn.(spannerdriver.SpannerConn).SetReadOnlyStaleness(o.spannerStaleness)
+		})
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return &staticExecutor{o: o, db: h.db, conn: conn}, nil
+	}
+
+	switch o.opts.DBPreparedStmt {
+	case OptDBPreparedStmtDisable:
+		return &staticExecutor{o: o, db: h.db}, nil
+	case OptDBPreparedStmtServer:
+		threadID := sysbench.ThreadID(ctx)
+		tc, err := o.threadConn(ctx, h, threadID)
+		if err != nil {
+			return nil, err
+		}
+		return &connExecutor{o: o, h: h, threadID: threadID, tc: tc}, nil
+	}
+	return &preparedExecutor{o: o, h: h}, nil
+}
+
+func (e *staticExecutor) runner() sqlRunner {
+	if e.tx != nil {
+		return e.tx
+	}
+	if e.conn != nil {
+		return e.conn
+	}
+	return e.db
+}
+
+func (e *staticExecutor) begin(ctx context.Context, txOpt *sql.TxOptions) error {
+	var err error
+	e.tx, err = e.o.beginTx(ctx, e.db, txOpt)
+	return err
+}
+
+func (e *staticExecutor) commit(ctx context.Context) error {
+	err := e.tx.Commit()
+	e.tx = nil
+	return err
+}
+
+func (e *staticExecutor) rollback() {
+	rollback(e.tx)
+	e.tx = nil
+}
+
+func (e *staticExecutor) query(ctx context.Context, tableNum int, stmtName string, args ...any) (*sql.Rows, error) {
+	return e.runner().QueryContext(ctx, e.o.staticStmts[tableNum][stmtName], e.o.bindArgs(args...)...)
+}
+
+func (e *staticExecutor) exec(ctx context.Context, tableNum int, stmtName string, args ...any) (sql.Result, error) {
+	return e.runner().ExecContext(ctx, e.o.staticStmts[tableNum][stmtName], e.o.bindArgs(args...)...)
+}
+
+func (e *staticExecutor) release() {
+	if e.conn != nil {
+		e.conn.Close()
+	}
+}
+
+func (e *preparedExecutor) stmt(ctx context.Context, tableNum int, stmtName string) *sql.Stmt {
+	if e.tx != nil {
+		return e.tx.StmtContext(ctx, e.h.preparedStmts[tableNum][stmtName])
+	}
+	return e.h.preparedStmts[tableNum][stmtName]
+}
+
+func (e *preparedExecutor) begin(ctx context.Context, txOpt *sql.TxOptions) error {
+	var err error
+	e.tx, err = e.o.beginTx(ctx, e.h.db, txOpt)
+	return err
+}
+
+func (e *preparedExecutor) commit(ctx context.Context) error {
+	err := e.tx.Commit()
+	e.tx = nil
+	return err
+}
+
+func (e *preparedExecutor) rollback() {
+	rollback(e.tx)
+	e.tx = nil
+}
+
+func (e *preparedExecutor) query(ctx context.Context, tableNum int, stmtName string, args ...any) (*sql.Rows, error) {
+	return e.stmt(ctx, tableNum, stmtName).QueryContext(ctx, e.o.bindArgs(args...)...)
+}
+
+func (e *preparedExecutor) exec(ctx context.Context, tableNum int, stmtName string, args ...any) (sql.Result, error) {
+	return e.stmt(ctx, tableNum, stmtName).ExecContext(ctx, e.o.bindArgs(args...)...)
+}
+
+func (e *preparedExecutor) release() {
+}
+
+// threadConn returns the connection of the thread to the host. It is opened and statements are prepared on it in the first event of the thread.
+func (o *OLTPBench) threadConn(ctx context.Context, h *dbHost, threadID int) (*threadConn, error) {
+	if tc, ok := h.threadConns.Load(threadID); ok {
+		return tc.(*threadConn), nil
+	}
+
+	conn, err := h.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tc := &threadConn{conn: conn, stmts: make(map[int]map[string]*sql.Stmt)}
+	for tableNum := range o.staticStmts {
+		tc.stmts[tableNum] = make(map[string]*sql.Stmt)
+		for _, stmtName := range h.stmtNames {
+			tc.stmts[tableNum][stmtName], err = conn.PrepareContext(ctx, o.staticStmts[tableNum][stmtName])
+			if err != nil {
+				tc.close()
+				return nil, err
+			}
+		}
+	}
+	h.threadConns.Store(threadID, tc)
+	return tc, nil
+}
+
+func (tc *threadConn) close() {
+	for _, stmts := range tc.stmts {
+		for _, stmt := range stmts {
+			if stmt != nil {
+				stmt.Close()
+			}
+		}
+	}
+	tc.conn.Close()
+}
+
+// closeThreadConns closes the connections of threads. It is called after all the threads finished.
+func (h *dbHost) closeThreadConns() {
+	h.threadConns.Range(func(threadID, tc any) bool {
+		tc.(*threadConn).close()
+		h.threadConns.Delete(threadID)
+		return true
+	})
+}
+
+// beginStmts returns the statements which begin a transaction with the options, as BeginTx of the drivers does.
+func (o *OLTPBench) beginStmts(txOpt *sql.TxOptions) []string {
+	var level string
+	if txOpt.Isolation != sql.LevelDefault {
+		level = "ISOLATION LEVEL " + strings.ToUpper(txOpt.Isolation.String())
+	}
+
+	if o.opts.DBDriver == DBDriverPgSQL {
+		stmt := "BEGIN"
+		if level != "" {
+			stmt += " " + level
+		}
+		if txOpt.ReadOnly {
+			stmt += " READ ONLY"
+		}
+		return []string{stmt}
+	}
+
+	var stmts []string
+	if level != "" {
+		// applies only to the next transaction
+		stmts = append(stmts, "SET TRANSACTION "+level)
+	}
+	if txOpt.ReadOnly {
+		return append(stmts, "START TRANSACTION READ ONLY")
+	}
+	return append(stmts, "START TRANSACTION")
+}
+
+// check closes the connection of the thread when it is broken, so that the next event opens a new one.
+func (e *connExecutor) check(err error) error {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) {
+		e.h.threadConns.Delete(e.threadID)
+		e.tc.close()
+		e.inTx = false
+	}
+	return err
+}
+
+func (e *connExecutor) begin(ctx context.Context, txOpt *sql.TxOptions) error {
+	for _, stmt := range e.o.beginStmts(txOpt) {
+		_, err := e.tc.conn.ExecContext(ctx, stmt)
+		if err != nil {
+			return e.check(err)
+		}
+	}
+	e.inTx = true
+	return nil
+}
+
+func (e *connExecutor) commit(ctx context.Context) error {
+	_, err := e.tc.conn.ExecContext(ctx, "COMMIT")
+	if err != nil {
+		// the transaction may remain open when COMMIT is not sent
+		e.rollback()
+		return err
+	}
+	e.inTx = false
+	return nil
+}
+
+func (e *connExecutor) rollback() {
+	if !e.inTx {
+		return
+	}
+	e.inTx = false
+
+	// the context of the event may have been canceled
+	_, err := e.tc.conn.ExecContext(context.Background(), "ROLLBACK")
+	if err != nil {
+		// the connection is in unknown state
+		_ = e.check(driver.ErrBadConn)
+	}
+}
+
+func (e *connExecutor) query(ctx context.Context, tableNum int, stmtName string, args ...any) (*sql.Rows, error) {
+	rows, err := e.tc.stmts[tableNum][stmtName].QueryContext(ctx, e.o.bindArgs(args...)...)
+	return rows, e.check(err)
+}
+
+func (e *connExecutor) exec(ctx context.Context, tableNum int, stmtName string, args ...any) (sql.Result, error) {
+	res, err := e.tc.stmts[tableNum][stmtName].ExecContext(ctx, e.o.bindArgs(args...)...)
+	return res, e.check(err)
+}
+
+func (e *connExecutor) release() {
+}
+
+// execCounted runs a write through the executor and returns whether it changed rows.
+// Writes which change no rows are counted as other queries rather than writes, as sysbench does.
+func execCounted(ctx context.Context, e stmtExecutor, tableNum int, stmtName string, args ...any) (bool, error) {
+	res, err := e.exec(ctx, tableNum, stmtName, args...)
+	if err != nil {
+		return false, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// queryAll discards the rows of a query run by an executor.
+func queryAll(rows *sql.Rows, err error) error {
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+	}
+	rows.Close()
+	return rows.Err()
+}
+
+// stmtRow is the first row of a query run by an executor, which is scanned as *sql.Row.
+type stmtRow struct {
+	rows *sql.Rows
+	err  error
+}
+
+func rowOf(rows *sql.Rows, err error) *stmtRow {
+	return &stmtRow{rows: rows, err: err}
+}
+
+// Scan copies the columns of the first row into dest. It returns sql.ErrNoRows if there is no row.
+func (r *stmtRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	defer r.rows.Close()
+
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	err := r.rows.Scan(dest...)
+	if err != nil {
+		return err
+	}
+	return r.rows.Close()
+}
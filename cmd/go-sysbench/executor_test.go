@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDriver accepts any statement, returns no rows and reports rowsAffected for writes.
+type fakeDriver struct {
+	mu           sync.Mutex
+	prepared     []string
+	rowsAffected int64
+}
+
+type (
+	fakeConn struct{ d *fakeDriver }
+	fakeStmt struct{ d *fakeDriver }
+	fakeRows struct{}
+	fakeTx   struct{}
+)
+
+var tFakeDriver = &fakeDriver{}
+
+func init() {
+	sql.Register("go-sysbench-fake", tFakeDriver)
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) { return &fakeConn{d}, nil }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.prepared = append(c.d.prepared, query)
+	return &fakeStmt{c.d}, nil
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return &fakeTx{}, nil }
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(s.d.rowsAffected), nil
+}
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) { return &fakeRows{}, nil }
+
+func (r *fakeRows) Columns() []string              { return []string{"c"} }
+func (r *fakeRows) Close() error                   { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error { return io.EOF }
+
+func (t *fakeTx) Commit() error   { return nil }
+func (t *fakeTx) Rollback() error { return nil }
+
+// fakeCase is a --db-ps-mode, and the number of rows which writes change on the fake driver.
+type fakeCase struct {
+	psMode       string
+	rowsAffected int64
+}
+
+func (c fakeCase) String() string {
+	return fmt.Sprintf("db-ps-mode=%s, rows affected=%d", c.psMode, c.rowsAffected)
+}
+
+// forEachFakeCase runs test with a bench on the fake driver in each --db-ps-mode, with writes which change a row and which change none.
+func forEachFakeCase(t *testing.T, opts *BenchmarkOpts, test func(o *OLTPBench, c fakeCase)) {
+	t.Helper()
+
+	for _, psMode := range []string{OptDBPreparedStmtAuto, OptDBPreparedStmtDisable, OptDBPreparedStmtServer} {
+		for _, rowsAffected := range []int64{0, 1} {
+			c := fakeCase{psMode: psMode, rowsAffected: rowsAffected}
+
+			opts.DBDriver = DBDriverMySQL
+			opts.DBPreparedStmt = psMode
+			opts.SkipTrx = OptOff
+			opts.Isolation = OptIsolationDefault
+
+			tFakeDriver.prepared = nil
+			tFakeDriver.rowsAffected = rowsAffected
+
+			db, err := sql.Open("go-sysbench-fake", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			o := newOLTPBench(opts, rwModeReadWrite)
+			o.db = db
+			o.hosts = []*dbHost{newDBHost(hostAddr{}, db)}
+
+			test(o, c)
+
+			if err = o.Done(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+// assertCounts compares the numbers of queries which an event returned with the expected ones.
+func assertCounts(t *testing.T, c fakeCase, reads, writes, others uint64, numReads, numWrites, numOthers uint64) {
+	t.Helper()
+
+	if numReads != reads || numWrites != writes || numOthers != others {
+		t.Errorf("%s: expected %d/%d/%d, got %d/%d/%d", c, reads, writes, others, numReads, numWrites, numOthers)
+	}
+}
+
+// numPrepared returns the number of statements prepared on the fake driver which contain substr.
+func numPrepared(substr string) int {
+	var n int
+	for _, query := range tFakeDriver.prepared {
+		if strings.Contains(query, substr) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestEventFuncOLTP(t *testing.T) {
+	const numEvents = 3
+	reads := uint64(numPointSelects + numSimpleRanges + numSumRanges + numOrderRanges + numDistinctRanges)
+	writes := uint64(numIndexUpdates + numNonIndexUpdates + numDeleteInserts*2)
+
+	opts := &BenchmarkOpts{}
+	opts.Tables = 1
+	opts.TableSize = 100
+	opts.TablePrefix = "sbtest"
+
+	forEachFakeCase(t, opts, func(o *OLTPBench, c fakeCase) {
+		if err := o.prepareStmts(context.Background(), o.formatStmts(stmtsMySQL), readStmtNames); err != nil {
+			t.Fatal(err)
+		}
+
+		eventFunc := o.eventFuncOLTP()
+		for i := 0; i < numEvents; i++ {
+			numReads, numWrites, numOthers, err := eventFunc(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// BEGIN and COMMIT, and writes which change no rows
+			if c.rowsAffected == 0 {
+				assertCounts(t, c, reads, 0, 2+writes, numReads, numWrites, numOthers)
+			} else {
+				assertCounts(t, c, reads, writes, 2, numReads, numWrites, numOthers)
+			}
+		}
+
+		if c.psMode == OptDBPreparedStmtServer {
+			if prepared := numPrepared("sbtest1"); prepared != len(stmtsMySQL) {
+				t.Errorf("Expected statements to be prepared once on the connection of the thread, got %d prepares", prepared)
+			}
+		}
+	})
+}
+
+func TestBeginStmts(t *testing.T) {
+	opts := &BenchmarkOpts{}
+	o := newOLTPBench(opts, rwModeReadWrite)
+
+	opts.DBDriver = DBDriverMySQL
+	stmts := o.beginStmts(&sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	expected := []string{"SET TRANSACTION ISOLATION LEVEL READ COMMITTED", "START TRANSACTION"}
+	if strings.Join(stmts, ";") != strings.Join(expected, ";") {
+		t.Errorf("Expected %q, got %q", expected, stmts)
+	}
+
+	opts.DBDriver = DBDriverPgSQL
+	stmts = o.beginStmts(&sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if len(stmts) != 1 || stmts[0] != "BEGIN ISOLATION LEVEL REPEATABLE READ READ ONLY" {
+		t.Errorf("Expected BEGIN ISOLATION LEVEL REPEATABLE READ READ ONLY, got %q", stmts)
+	}
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/samitani/go-sysbench"
+)
+
+const (
+	NameFileIO = "fileio"
+
+	OptFileTestModeSeqWr   = "seqwr"
+	OptFileTestModeSeqRewr = "seqrewr"
+	OptFileTestModeSeqRd   = "seqrd"
+	OptFileTestModeRndRd   = "rndrd"
+	OptFileTestModeRndWr   = "rndwr"
+	OptFileTestModeRndRw   = "rndrw"
+
+	OptFileExtraFlagDirect = "direct"
+	OptFileExtraFlagSync   = "sync"
+	OptFileExtraFlagDsync  = "dsync"
+
+	// buffers are aligned for O_DIRECT
+	fileBufferAlignment = 4096
+
+	fileNameFormat = "test_file.%d"
+)
+
+type (
+	FileIOOpts struct {
+		FileNum        int     `long:"file-num" description:"number of files to create" default:"128"`
+		FileBlockSize  int     `long:"file-block-size" description:"block size to use in all IO operations" default:"16384"`
+		FileTotalSize  string  `long:"file-total-size" description:"total size of files to create" default:"2G"`
+		FileTestMode   string  `long:"file-test-mode" choice:"seqwr" choice:"seqrewr" choice:"seqrd" choice:"rndrd" choice:"rndwr" choice:"rndrw" description:"test mode"` //nolint:staticcheck
+		FileFsyncFreq  int     `long:"file-fsync-freq" description:"do fsync() after this number of requests (0 - don't use fsync())" default:"100"`
+		FileExtraFlags string  `long:"file-extra-flags" description:"comma separated list of additional flags to use to open files {sync,dsync,direct}"`
+		FileRWRatio    float64 `long:"file-rw-ratio" description:"reads/writes ratio for combined test" default:"1.5"`
+	}
+
+	// FileIOBench is equivalent to sysbench fileio with the synchronous I/O mode.
+	// Each event is a read or write of a block, or fsync of a file.
+	// https://github.com/akopytov/sysbench/blob/1.0.20/src/tests/fileio/sb_fileio.c
+	FileIOBench struct {
+		opts *FileIOOpts
+
+		totalSize  int64 // rounded down to whole blocks of all files
+		fileSize   int64
+		roundedOff int64 // bytes of --file-total-size which do not fill a block
+		extraFlags int
+
+		files     []*os.File
+		buffers   sync.Map      // thread id -> []byte
+		requests  atomic.Int64  // number of requests including fsync
+		seqBlock  atomic.Int64  // next block of sequential tests
+		fsyncFile atomic.Uint64 // next file to fsync
+	}
+)
+
+func newFileIOBench(option *FileIOOpts) *FileIOBench {
+	return &FileIOBench{opts: option}
+}
+
+func (f *FileIOBench) Init(ctx context.Context) error {
+	var err error
+
+	f.totalSize, err = parseSize(f.opts.FileTotalSize)
+	if err != nil {
+		return err
+	}
+	if f.opts.FileNum < 1 {
+		return fmt.Errorf("--file-num should be >= 1")
+	}
+	if f.opts.FileBlockSize < 1 {
+		return fmt.Errorf("--file-block-size should be >= 1")
+	}
+
+	f.fileSize = f.totalSize / int64(f.opts.FileNum)
+	if f.fileSize < int64(f.opts.FileBlockSize) {
+		return fmt.Errorf("file size %d is smaller than --file-block-size", f.fileSize)
+	}
+
+	// files consist of whole blocks, since events read and write blocks and O_DIRECT requires aligned sizes
+	f.fileSize -= f.fileSize % int64(f.opts.FileBlockSize)
+	f.roundedOff = f.totalSize - f.fileSize*int64(f.opts.FileNum)
+	f.totalSize -= f.roundedOff
+
+	f.extraFlags, err = parseFileExtraFlags(f.opts.FileExtraFlags)
+	if err != nil {
+		return err
+	}
+	if f.extraFlags&oDirect != 0 && f.opts.FileBlockSize%fileBufferAlignment != 0 {
+		return fmt.Errorf("--file-block-size should be a multiple of %d with --file-extra-flags=%s", fileBufferAlignment, OptFileExtraFlagDirect)
+	}
+
+	return nil
+}
+
+// parseFileExtraFlags converts --file-extra-flags to open flags.
+func parseFileExtraFlags(flags string) (int, error) {
+	var flag int
+
+	if flags == "" {
+		return 0, nil
+	}
+
+	for _, s := range strings.Split(flags, ",") {
+		switch strings.TrimSpace(s) {
+		case OptFileExtraFlagSync:
+			flag |= os.O_SYNC
+		case OptFileExtraFlagDsync:
+			if oDsync == 0 {
+				return 0, fmt.Errorf("--file-extra-flags=%s is not supported on this platform", OptFileExtraFlagDsync)
+			}
+			flag |= oDsync
+		case OptFileExtraFlagDirect:
+			if oDirect == 0 {
+				return 0, fmt.Errorf("--file-extra-flags=%s is not supported on this platform", OptFileExtraFlagDirect)
+			}
+			flag |= oDirect
+		default:
+			return 0, fmt.Errorf("invalid --file-extra-flags: %s", flags)
+		}
+	}
+	return flag, nil
+}
+
+func (f *FileIOBench) Done() error {
+	for _, file := range f.files {
+		file.Close()
+	}
+	return nil
+}
+
+// Prepare creates --file-num files filled with zeros.
+func (f *FileIOBench) Prepare(ctx context.Context) error {
+	f.printRoundedOff()
+	fmt.Printf("%d files, %s each, %s total\n", f.opts.FileNum, formatSize(f.fileSize, 1024, "KiB"), formatSize(f.totalSize, mebibyte, "MiB"))
+	fmt.Println("Creating files for the test...")
+	fmt.Printf("Extra file open flags: %s\n", f.extraFlagsString())
+
+	buf := alignedBuffer(f.opts.FileBlockSize)
+	begin := time.Now()
+
+	var written int64
+	for i := 0; i < f.opts.FileNum; i++ {
+		name := fmt.Sprintf(fileNameFormat, i)
+		fmt.Printf("Creating file %s\n", name)
+
+		file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC|f.extraFlags, 0644)
+		if err != nil {
+			return err
+		}
+
+		for offset := int64(0); offset+int64(len(buf)) <= f.fileSize; offset += int64(len(buf)) {
+			n, err := file.Write(buf)
+			written += int64(n)
+			if err != nil {
+				file.Close()
+				return err
+			}
+		}
+
+		err = file.Sync()
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	seconds := time.Since(begin).Seconds()
+	fmt.Printf("\n%d bytes written in %.2f seconds (%.2f MiB/sec).\n", written, seconds, float64(written)/mebibyte/seconds)
+
+	return nil
+}
+
+// Cleanup removes the files created by prepare.
+func (f *FileIOBench) Cleanup(ctx context.Context) error {
+	fmt.Println("Removing test files...")
+
+	for i := 0; i < f.opts.FileNum; i++ {
+		err := os.Remove(fmt.Sprintf(fileNameFormat, i))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *FileIOBench) PreEvent(ctx context.Context) error {
+	if f.opts.FileTestMode == "" {
+		return fmt.Errorf("missing required argument: --file-test-mode")
+	}
+
+	f.printRoundedOff()
+	fmt.Printf("Extra file open flags: %s\n"+
+		"%d files, %s each\n"+
+		"%s total file size\n"+
+		"Block size %s\n",
+		f.extraFlagsString(), f.opts.FileNum, formatSize(f.fileSize, mebibyte, "MiB"), formatSize(f.totalSize, mebibyte, "MiB"),
+		formatSize(int64(f.opts.FileBlockSize), 1024, "KiB"))
+	if f.opts.FileTestMode == OptFileTestModeRndRw {
+		fmt.Printf("Read/Write ratio for combined random IO test: %.2f\n", f.opts.FileRWRatio)
+	}
+	if f.opts.FileFsyncFreq > 0 && f.writes() {
+		fmt.Printf("Periodic FSYNC enabled, calling fsync() each %d requests.\n", f.opts.FileFsyncFreq)
+	}
+	fmt.Printf("Using synchronous I/O mode\n\n")
+
+	flag := os.O_RDWR | f.extraFlags
+	if f.opts.FileTestMode == OptFileTestModeSeqWr {
+		// files are written from scratch unlike seqrewr
+		flag |= os.O_TRUNC
+	}
+
+	for i := 0; i < f.opts.FileNum; i++ {
+		file, err := os.OpenFile(fmt.Sprintf(fileNameFormat, i), flag, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open test files. run 'prepare' with the same --file-num first: %w", err)
+		}
+		f.files = append(f.files, file)
+	}
+	return nil
+}
+
+func (f *FileIOBench) printRoundedOff() {
+	if f.roundedOff > 0 {
+		fmt.Printf("--file-total-size is rounded down to %d bytes to make each file a multiple of --file-block-size\n", f.totalSize)
+	}
+}
+
+// writes returns true if the test mode writes files.
+func (f *FileIOBench) writes() bool {
+	return f.opts.FileTestMode == OptFileTestModeSeqWr || f.opts.FileTestMode == OptFileTestModeSeqRewr ||
+		f.opts.FileTestMode == OptFileTestModeRndWr || f.opts.FileTestMode == OptFileTestModeRndRw
+}
+
+func (f *FileIOBench) extraFlagsString() string {
+	if f.opts.FileExtraFlags == "" {
+		return "(none)"
+	}
+	return f.opts.FileExtraFlags
+}
+
+// buffer returns the I/O buffer of the thread.
+func (f *FileIOBench) buffer(ctx context.Context) []byte {
+	threadID := sysbench.ThreadID(ctx)
+	buf, ok := f.buffers.Load(threadID)
+	if !ok {
+		buf = alignedBuffer(f.opts.FileBlockSize)
+		f.buffers.Store(threadID, buf)
+	}
+	return buf.([]byte)
+}
+
+// alignedBuffer allocates a buffer whose address is aligned for O_DIRECT.
+func alignedBuffer(size int) []byte {
+	buf := make([]byte, size+fileBufferAlignment)
+	offset := int(uintptr(unsafe.Pointer(&buf[0])) & (fileBufferAlignment - 1))
+	if offset == 0 {
+		return buf[:size]
+	}
+	return buf[fileBufferAlignment-offset : fileBufferAlignment-offset+size]
+}
+
+func (f *FileIOBench) Event(ctx context.Context) (numReads, numWrites, numOthers, numIgnoredErros uint64, err error) {
+	if f.writes() && f.opts.FileFsyncFreq > 0 && f.requests.Add(1)%int64(f.opts.FileFsyncFreq) == 0 {
+		file := f.files[(f.fsyncFile.Add(1)-1)%uint64(len(f.files))]
+		return 0, 0, 1, 0, file.Sync()
+	}
+
+	var file *os.File
+	var offset int64
+	blocksPerFile := f.fileSize / int64(f.opts.FileBlockSize)
+
+	if strings.HasPrefix(f.opts.FileTestMode, "seq") {
+		// sequential tests end after a pass over all the files
+		block := f.seqBlock.Add(1) - 1
+		if block >= blocksPerFile*int64(len(f.files)) {
+			return 0, 0, 0, 0, sysbench.ErrNoMoreEvents
+		}
+		file = f.files[block/blocksPerFile]
+		offset = (block % blocksPerFile) * int64(f.opts.FileBlockSize)
+	} else {
+		file = f.files[rand.Intn(len(f.files))]
+		offset = rand.Int63n(blocksPerFile) * int64(f.opts.FileBlockSize)
+	}
+
+	buf := f.buffer(ctx)
+
+	read := f.opts.FileTestMode == OptFileTestModeSeqRd || f.opts.FileTestMode == OptFileTestModeRndRd
+	if f.opts.FileTestMode == OptFileTestModeRndRw {
+		read = rand.Float64() < f.opts.FileRWRatio/(f.opts.FileRWRatio+1)
+	}
+
+	if read {
+		_, err = file.ReadAt(buf, offset)
+		return 1, 0, 0, 0, err
+	}
+	_, err = file.WriteAt(buf, offset)
+	return 0, 1, 0, 0, err
+}
+
+// Summary prints file operations and throughput in the same format as sysbench fileio.
+func (f *FileIOBench) Summary(result *sysbench.Result) {
+	seconds := result.TotalTime.Seconds()
+
+	fmt.Printf("File operations:\n"+
+		"    reads/s:                      %4.2f\n"+
+		"    writes/s:                     %4.2f\n"+
+		"    fsyncs/s:                     %4.2f\n\n"+
+		"Throughput:\n"+
+		"    read, MiB/s:                  %4.2f\n"+
+		"    written, MiB/s:               %4.2f\n\n",
+		float64(result.TotalReads)/seconds,
+		float64(result.TotalWrites)/seconds,
+		float64(result.TotalOthers)/seconds,
+		float64(result.TotalReads)*float64(f.opts.FileBlockSize)/mebibyte/seconds,
+		float64(result.TotalWrites)*float64(f.opts.FileBlockSize)/mebibyte/seconds)
+}
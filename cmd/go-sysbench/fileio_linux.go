@@ -0,0 +1,11 @@
+package main
+
+import (
+	"syscall"
+)
+
+// open flags for --file-extra-flags
+const (
+	oDirect = syscall.O_DIRECT
+	oDsync  = syscall.O_DSYNC
+)
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+// --file-extra-flags=direct and dsync are supported only on Linux
+const (
+	oDirect = 0
+	oDsync  = 0
+)
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"unsafe"
+)
+
+func TestParseFileExtraFlags(t *testing.T) {
+	flag, err := parseFileExtraFlags("sync")
+	if err != nil || flag != os.O_SYNC {
+		t.Errorf("Expected O_SYNC, got %d (%v)", flag, err)
+	}
+
+	if flag, err = parseFileExtraFlags(""); err != nil || flag != 0 {
+		t.Errorf("Expected no flags, got %d (%v)", flag, err)
+	}
+
+	for _, input := range []string{"async", "sync,"} {
+		if _, err := parseFileExtraFlags(input); err == nil {
+			t.Errorf("Expected %q to be invalid", input)
+		}
+	}
+}
+
+func TestAlignedBuffer(t *testing.T) {
+	for _, size := range []int{512, 4096, 16384} {
+		buf := alignedBuffer(size)
+		if len(buf) != size {
+			t.Errorf("Expected %d bytes, got %d", size, len(buf))
+		}
+		if uintptr(unsafe.Pointer(&buf[0]))%fileBufferAlignment != 0 {
+			t.Errorf("Expected the buffer of %d bytes to be aligned to %d", size, fileBufferAlignment)
+		}
+	}
+}
+
+func TestFileIOPrepareRoundsSize(t *testing.T) {
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(dir)
+
+	// 5000 bytes per file, which is 4 blocks and 200 bytes
+	f := newFileIOBench(&FileIOOpts{FileNum: 2, FileBlockSize: 1200, FileTotalSize: "10000"})
+	if err = f.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if f.fileSize != 4800 || f.totalSize != 9600 {
+		t.Errorf("Expected 4800 bytes per file and 9600 bytes in total, got %d and %d", f.fileSize, f.totalSize)
+	}
+
+	if err = f.Prepare(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < f.opts.FileNum; i++ {
+		info, err := os.Stat(fmt.Sprintf(fileNameFormat, i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() != f.fileSize {
+			t.Errorf("Expected %d bytes in %s, got %d", f.fileSize, info.Name(), info.Size())
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/samitani/go-sysbench"
+)
+
+const nano2mili = 1000000.0
+
+type (
+	// hostAddr is an entry of --mysql-host or --pgsql-host
+	hostAddr struct {
+		host string
+		port int
+	}
+
+	// latencyStats is the number and latency of succeeded events.
+	latencyStats struct {
+		events         atomic.Uint64
+		latencyNanoSum atomic.Uint64
+		latencyNanoMax atomic.Uint64
+		histogram      *sysbench.Histogram
+	}
+
+	// dbHost is the connection pool to one host, and the stats of events run on it.
+	dbHost struct {
+		latencyStats
+
+		addr          hostAddr
+		db            *sql.DB
+		stmtNames     []string                     // statements which run on the host
+		preparedStmts map[int]map[string]*sql.Stmt // tableNum -> stmtName -> preparedStmt
+		threadConns   sync.Map                     // threadID -> *threadConn
+	}
+)
+
+func (a hostAddr) String() string {
+	return net.JoinHostPort(a.host, strconv.Itoa(a.port))
+}
+
+// parseHosts splits a comma separated list of hosts. Each host may have its own port as host:port.
+func parseHosts(hosts string, defaultPort int) ([]hostAddr, error) {
+	var addrs []hostAddr
+
+	for _, h := range strings.Split(hosts, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			return nil, fmt.Errorf("invalid host list: %s", hosts)
+		}
+
+		host, portStr, err := net.SplitHostPort(h)
+		if err != nil {
+			// no port, or an IPv6 address without brackets. brackets are added back by hostAddr.String()
+			if strings.HasPrefix(h, "[") && strings.HasSuffix(h, "]") {
+				h = h[1 : len(h)-1]
+			}
+			if h == "" {
+				return nil, fmt.Errorf("invalid host list: %s", hosts)
+			}
+			addrs = append(addrs, hostAddr{host: h, port: defaultPort})
+			continue
+		}
+
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port in host list: %s", h)
+		}
+		addrs = append(addrs, hostAddr{host: host, port: port})
+	}
+	return addrs, nil
+}
+
+func newDBHost(addr hostAddr, db *sql.DB) *dbHost {
+	return &dbHost{latencyStats: newLatencyStats(), addr: addr, db: db}
+}
+
+func newLatencyStats() latencyStats {
+	return latencyStats{histogram: sysbench.NewLatencyHistogram()}
+}
+
+// record adds the latency of a succeeded event.
+func (s *latencyStats) record(latency time.Duration) {
+	nano := uint64(latency.Nanoseconds())
+
+	s.events.Add(1)
+	s.latencyNanoSum.Add(nano)
+	for {
+		currentMax := s.latencyNanoMax.Load()
+		if nano <= currentMax || s.latencyNanoMax.CompareAndSwap(currentMax, nano) {
+			break
+		}
+	}
+	s.histogram.Add(float64(nano) / nano2mili)
+}
+
+// hostFor returns the host assigned to the thread. Threads are distributed to hosts in round-robin.
+func (o *OLTPBench) hostFor(ctx context.Context) *dbHost {
+	return o.hosts[sysbench.ThreadID(ctx)%len(o.hosts)]
+}
+
+// readHostFor returns the replica assigned to the thread, or nil without --read-hosts.
+func (o *OLTPBench) readHostFor(ctx context.Context) *dbHost {
+	if len(o.readHosts) == 0 {
+		return nil
+	}
+	return o.readHosts[sysbench.ThreadID(ctx)%len(o.readHosts)]
+}
+
+// rollback rolls back the transaction if it has begun.
+func rollback(tx *sql.Tx) {
+	if tx != nil {
+		_ = tx.Rollback()
+	}
+}
+
+// reportHosts prints tps and latency per host when more than one host or --read-hosts is given.
+// With --read-hosts, latency of primary hosts is that of write transactions,
+// and latency of replicas is that of the SELECT statements in an event.
+func (o *OLTPBench) reportHosts(result *sysbench.Result) {
+	if len(o.hosts) < 2 && len(o.readHosts) == 0 {
+		return
+	}
+
+	fmt.Println("\nPer host statistics:")
+	if len(o.readHosts) == 0 {
+		for _, h := range o.hosts {
+			h.print(h.addr.String(), "transactions", result)
+		}
+		return
+	}
+
+	for _, h := range o.hosts {
+		h.print(h.addr.String()+" (primary)", "transactions", result)
+	}
+	for _, h := range o.readHosts {
+		h.print(h.addr.String()+" (replica)", "reads", result)
+	}
+}
+
+func (s *latencyStats) print(title string, eventName string, result *sysbench.Result) {
+	events := s.events.Load()
+
+	var avg float64
+	if events > 0 {
+		avg = float64(s.latencyNanoSum.Load()) / nano2mili / float64(events)
+	}
+
+	fmt.Printf("    %s\n"+
+		"        %-33s%-6d (%.2f per sec.)\n"+
+		"        latency avg (ms): %26.2f\n"+
+		"        latency max (ms): %26.2f\n"+
+		"        latency %dth percentile (ms): %15.2f\n",
+		title,
+		eventName+":", events, float64(events)/result.TotalTime.Seconds(),
+		avg,
+		float64(s.latencyNanoMax.Load())/nano2mili,
+		result.Percentile, s.histogram.Percentile(result.Percentile))
+}
@@ -0,0 +1,494 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/googleapis/go-sql-spanner"
+	"github.com/lib/pq"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/samitani/go-sysbench"
+)
+
+const (
+	NameHotRows = "hot_rows"
+
+	OptHotPatternSelectForUpdate = "select-for-update"
+	OptHotPatternSelectUpdate    = "select-update"
+	OptHotPatternAtomic          = "atomic"
+
+	hotSelect = "select"
+	hotUpdate = "update"
+
+	// the hot table is the only table of the statements
+	hotTableNum = 1
+)
+
+// names of error codes which contention causes
+var errorCodeNames map[string]string = map[string]string{
+	"1205":    "lock wait timeout",
+	"1213":    "deadlock",
+	"40001":   "serialization failure",
+	"40P01":   "deadlock",
+	"55P03":   "lock not available",
+	"Aborted": "transaction aborted",
+}
+
+type (
+	HotRowsOpts struct {
+		HotRows    int    `long:"hot-rows" description:"number of rows which events update" default:"10"`
+		HotPattern string `long:"hot-pattern" choice:"select-for-update" choice:"select-update" choice:"atomic" description:"how to increment a counter. select-for-update and select-update read the counter in a transaction, with and without a lock, and write it back. atomic runs UPDATE SET counter=counter+1" default:"select-for-update"` //nolint:staticcheck
+	}
+
+	// HotRowsBench increments counters on a small number of rows to cause lock contention.
+	// Increments of committed events are compared with the counters after the run.
+	HotRowsBench struct {
+		*OLTPBench
+
+		countersBefore []int64
+		countersAfter  []int64
+		increments     []atomic.Int64 // committed increments of each row
+		unknown        []atomic.Int64 // increments of each row whose commit failed without telling the outcome
+
+		errMu     sync.Mutex
+		errCounts map[string]uint64 // error code -> number of errors
+	}
+)
+
+func newHotRowsBench(option *BenchmarkOpts) *HotRowsBench {
+	return &HotRowsBench{OLTPBench: newOLTPBench(option, rwModeReadWrite), errCounts: make(map[string]uint64)}
+}
+
+func (t *HotRowsBench) Init(ctx context.Context) error {
+	if t.opts.HotRows <= 0 {
+		return fmt.Errorf("Invalid value for hot-rows: %d", t.opts.HotRows)
+	}
+	if t.opts.ReadHosts != "" {
+		return fmt.Errorf("--read-hosts is not supported by '%s' test", NameHotRows)
+	}
+	// read-modify-write patterns need a transaction
+	if t.opts.SkipTrx == OptOn && t.opts.HotPattern != OptHotPatternAtomic {
+		return fmt.Errorf("--skip-trx is supported only with --hot-pattern=%s", OptHotPatternAtomic)
+	}
+	return t.OLTPBench.Init(ctx)
+}
+
+func (t *HotRowsBench) hotTableName() string {
+	return t.opts.TablePrefix + "_hot"
+}
+
+func (t *HotRowsBench) Prepare(ctx context.Context) error {
+	var query string
+
+	table := t.hotTableName()
+
+	fmt.Printf("Creating table '%s'...\n", table)
+	if t.opts.DBDriver == DBDriverSpanner && t.opts.SpannerDialect == OptSpannerDialectPostgreSQL {
+		query = fmt.Sprintf("CREATE TABLE %s (id BIGINT NOT NULL, counter BIGINT NOT NULL, PRIMARY KEY (id)) %s", table, t.opts.CreateTableOpts)
+	} else if t.opts.DBDriver == DBDriverSpanner {
+		query = fmt.Sprintf("CREATE TABLE %s (id INT64 NOT NULL, counter INT64 NOT NULL) PRIMARY KEY (id) %s", table, t.opts.CreateTableOpts)
+	} else if t.opts.DBDriver == DBDriverPgSQL {
+		query = fmt.Sprintf("CREATE TABLE %s (id INT NOT NULL, counter BIGINT NOT NULL, PRIMARY KEY (id)) %s", table, t.opts.CreateTableOpts)
+	} else {
+		query = fmt.Sprintf("CREATE TABLE %s (id INT NOT NULL, counter BIGINT NOT NULL, PRIMARY KEY (id)) /*! ENGINE = %s */ %s", table, t.opts.MySQLEngine, t.opts.CreateTableOpts)
+	}
+
+	_, err := t.db.ExecContext(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Inserting %d records into '%s'\n", t.opts.HotRows, table)
+	insertValues := []string{}
+	for id := 1; id <= t.opts.HotRows; id++ {
+		insertValues = append(insertValues, fmt.Sprintf("(%d, 0)", id))
+
+		if id%500 == 0 || id == t.opts.HotRows {
+			_, err = t.db.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (id, counter) VALUES %s", table, strings.Join(insertValues, ",")))
+			if err != nil {
+				return err
+			}
+			insertValues = []string{}
+		}
+	}
+	return nil
+}
+
+func (t *HotRowsBench) PreEvent(ctx context.Context) error {
+	var err error
+
+	table := t.hotTableName()
+	pgsql := t.opts.DBDriver == DBDriverPgSQL || (t.opts.DBDriver == DBDriverSpanner && t.opts.SpannerDialect == OptSpannerDialectPostgreSQL)
+
+	if t.opts.DBDriver == DBDriverSpanner {
+		err = t.setupSpanner()
+		if err != nil {
+			return err
+		}
+	}
+
+	lockMode := OptLockingReadsOff
+	if t.opts.HotPattern == OptHotPatternSelectForUpdate {
+		lockMode = OptLockingReadsForUpdate
+	}
+
+	stmts := make(map[string]string)
+	if pgsql {
+		stmts[hotSelect] = t.lockingRead(fmt.Sprintf("SELECT counter FROM %s WHERE id=$1", table), lockMode)
+		if t.opts.HotPattern == OptHotPatternAtomic {
+			stmts[hotUpdate] = fmt.Sprintf("UPDATE %s SET counter=counter+1 WHERE id=$1", table)
+		} else {
+			stmts[hotUpdate] = fmt.Sprintf("UPDATE %s SET counter=$1 WHERE id=$2", table)
+		}
+	} else {
+		stmts[hotSelect] = t.lockingRead(fmt.Sprintf("SELECT counter FROM %s WHERE id=?", table), lockMode)
+		if t.opts.HotPattern == OptHotPatternAtomic {
+			stmts[hotUpdate] = fmt.Sprintf("UPDATE %s SET counter=counter+1 WHERE id=?", table)
+		} else {
+			stmts[hotUpdate] = fmt.Sprintf("UPDATE %s SET counter=? WHERE id=?", table)
+		}
+	}
+
+	t.countersBefore, err = t.readCounters(ctx)
+	if err != nil {
+		return fmt.Errorf("table '%s' is not accessible. run prepare with the same --table-prefix first: %w", table, err)
+	}
+	if len(t.countersBefore) != t.opts.HotRows {
+		return fmt.Errorf("table '%s' has %d rows, which does not match --hot-rows=%d. prepare and run should use the same --hot-rows", table, len(t.countersBefore), t.opts.HotRows)
+	}
+	t.increments = make([]atomic.Int64, t.opts.HotRows)
+	t.unknown = make([]atomic.Int64, t.opts.HotRows)
+
+	err = t.prepareStmts(ctx, map[int]map[string]string{hotTableNum: stmts}, nil)
+	if err != nil {
+		return err
+	}
+
+	err = t.printIsolation(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(t.lagHosts) > 0 {
+		err = t.createHeartbeat(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Hot rows: %d\n", t.opts.HotRows)
+	fmt.Printf("Pattern: %s\n\n", t.opts.HotPattern)
+
+	if t.opts.ServerMetrics != "" {
+		err = t.startServerMetrics(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readCounters returns the counters ordered by id.
+func (t *HotRowsBench) readCounters(ctx context.Context) ([]int64, error) {
+	var counters []int64
+
+	rows, err := t.db.QueryContext(ctx, fmt.Sprintf("SELECT id, counter FROM %s ORDER BY id", t.hotTableName()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, counter int64
+		err = rows.Scan(&id, &counter)
+		if err != nil {
+			return nil, err
+		}
+		if id != int64(len(counters)+1) {
+			return nil, fmt.Errorf("table '%s' does not have id %d", t.hotTableName(), len(counters)+1)
+		}
+		counters = append(counters, counter)
+	}
+	return counters, rows.Err()
+}
+
+func (t *HotRowsBench) Event(ctx context.Context) (numReads, numWrites, numOthers, numIgnoredErros uint64, err error) {
+	return t.runEvent(ctx, func(ctx context.Context) (uint64, uint64, uint64, error) {
+		numReads, numWrites, numOthers, err := t.hotEvent(ctx)
+		// errors after the end of the run are not caused by contention
+		if err != nil && ctx.Err() == nil {
+			t.countError(err)
+		}
+		return numReads, numWrites, numOthers, err
+	})
+}
+
+// hotEvent increments the counter of a random row through the executor of --db-ps-mode.
+func (t *HotRowsBench) hotEvent(ctx context.Context) (numReads, numWrites, numOthers uint64, err error) {
+	id := sbRand(1, t.opts.HotRows)
+
+	e, err := t.executorFor(ctx, t.hostFor(ctx))
+	if err != nil {
+		return numReads, numWrites, numOthers, err
+	}
+	defer e.release()
+
+	exec := func(args ...any) (bool, error) {
+		changed, err := execCounted(ctx, e, hotTableNum, hotUpdate, args...)
+		if err != nil {
+			return false, err
+		}
+		if changed {
+			numWrites += 1
+		} else {
+			numOthers += 1
+		}
+		return changed, nil
+	}
+
+	if t.opts.HotPattern == OptHotPatternAtomic {
+		changed, err := exec(id)
+		if err != nil {
+			if outcomeUnknown(err) {
+				t.unknown[id-1].Add(1)
+			}
+			return numReads, numWrites, numOthers, err
+		}
+		// an update which changed no rows did not increment the counter
+		if changed {
+			t.increments[id-1].Add(1)
+		}
+		return numReads, numWrites, numOthers, nil
+	}
+
+	err = e.begin(ctx, t.txOptions(false))
+	if err != nil {
+		return numReads, numWrites, numOthers, err
+	}
+	numOthers += 1
+
+	var counter int64
+	err = rowOf(e.query(ctx, hotTableNum, hotSelect, id)).Scan(&counter)
+	if err != nil {
+		e.rollback()
+		return numReads, numWrites, numOthers, err
+	}
+	numReads += 1
+
+	changed, err := exec(counter+1, id)
+	if err != nil {
+		e.rollback()
+		return numReads, numWrites, numOthers, err
+	}
+
+	err = e.commit(ctx)
+	if err != nil {
+		if outcomeUnknown(err) && changed {
+			t.unknown[id-1].Add(1)
+		}
+		return numReads, numWrites, numOthers, err
+	}
+	numOthers += 1
+	if changed {
+		t.increments[id-1].Add(1)
+	}
+
+	return numReads, numWrites, numOthers, nil
+}
+
+// errorCode returns the error code of a database error.
+func errorCode(err error) string {
+	var me *mysql.MySQLError
+	if errors.As(err, &me) {
+		return strconv.Itoa(int(me.Number))
+	}
+	var pe *pq.Error
+	if errors.As(err, &pe) {
+		return string(pe.Code)
+	}
+	if errors.Is(err, spannerdriver.ErrAbortedDueToConcurrentModification) {
+		return "Aborted"
+	}
+	if s, ok := status.FromError(err); ok {
+		return s.Code().String()
+	}
+	return "other"
+}
+
+// outcomeUnknown returns true if the error of a commit does not tell whether it was committed,
+// such as a lost connection or a context canceled while the commit is in flight.
+// Errors returned by the server mean that the transaction was rolled back.
+func outcomeUnknown(err error) bool {
+	var me *mysql.MySQLError
+	var pe *pq.Error
+	if errors.As(err, &me) || errors.As(err, &pe) || errors.Is(err, sql.ErrTxDone) {
+		return false
+	}
+	if errors.Is(err, spannerdriver.ErrAbortedDueToConcurrentModification) {
+		return false
+	}
+	if s, ok := status.FromError(err); ok && s.Code() == codes.Aborted {
+		return false
+	}
+	return true
+}
+
+func (t *HotRowsBench) countError(err error) {
+	code := errorCode(err)
+
+	t.errMu.Lock()
+	defer t.errMu.Unlock()
+	t.errCounts[code]++
+}
+
+func (t *HotRowsBench) Done() error {
+	// counters are read before the connections are closed
+	if t.countersBefore != nil {
+		var err error
+		t.countersAfter, err = t.readCounters(context.Background())
+		if err != nil {
+			fmt.Printf("failed to read counters: %s\n", err)
+		}
+	}
+	return t.OLTPBench.Done()
+}
+
+// Report prints errors per code and the result of the counter verification after the statistics of OLTPBench.
+func (t *HotRowsBench) Report(result *sysbench.Result) {
+	t.OLTPBench.Report(result)
+
+	var committed int64
+	for i := range t.increments {
+		committed += t.increments[i].Load()
+	}
+
+	t.errMu.Lock()
+	codes := make([]string, 0, len(t.errCounts))
+	var numErrors uint64
+	for code, count := range t.errCounts {
+		codes = append(codes, code)
+		numErrors += count
+	}
+	sort.Strings(codes)
+
+	fmt.Println("\nHot rows statistics:")
+	fmt.Printf("    committed increments: %21d (%.2f per sec.)\n", committed, float64(committed)/result.TotalTime.Seconds())
+	fmt.Printf("    errors: %35d (%.2f per sec.)\n", numErrors, float64(numErrors)/result.TotalTime.Seconds())
+	for _, code := range codes {
+		count := t.errCounts[code]
+		fmt.Printf("        %-36s%-6d (%.2f per sec., %.2f%% of attempts)\n",
+			code+" "+errorCodeNames[code]+":", count, float64(count)/result.TotalTime.Seconds(), float64(count)*100/float64(uint64(committed)+numErrors))
+	}
+	t.errMu.Unlock()
+
+	var numUnknown int64
+	for i := range t.unknown {
+		numUnknown += t.unknown[i].Load()
+	}
+	if numUnknown > 0 {
+		fmt.Printf("    commits with unknown outcome: %13d\n", numUnknown)
+	}
+
+	if t.countersAfter == nil {
+		fmt.Println("    counter verification:                 skipped")
+		return
+	}
+
+	committedRows := make([]int64, len(t.increments))
+	unknownRows := make([]int64, len(t.unknown))
+	for i := range t.increments {
+		committedRows[i] = t.increments[i].Load()
+		unknownRows[i] = t.unknown[i].Load()
+	}
+
+	v := verifyCounters(t.countersBefore, t.countersAfter, committedRows, unknownRows)
+	if v.mismatches > 0 {
+		fmt.Printf("    counter verification:                 FAILED (%d rows differ, %d increments lost, %d unexpected increments)\n", v.mismatches, v.lost, v.unexpected)
+	} else if numUnknown > 0 {
+		fmt.Printf("    counter verification:                 OK (%d of %d commits with unknown outcome applied)\n", v.applied, numUnknown)
+	} else {
+		fmt.Printf("    counter verification:                 OK\n")
+	}
+}
+
+type counterVerification struct {
+	mismatches int   // rows whose counter is out of the range which the commits explain
+	lost       int64 // committed increments which are not in the counters
+	unexpected int64 // increments which no commit explains
+	applied    int64 // commits with unknown outcome which were applied
+}
+
+// verifyCounters checks that each counter was incremented by the committed events, and at most by the commits with unknown outcome in addition.
+func verifyCounters(before, after, committed, unknown []int64) counterVerification {
+	var v counterVerification
+
+	for i := range after {
+		diff := after[i] - before[i]
+		if diff < committed[i] {
+			v.mismatches++
+			v.lost += committed[i] - diff
+		} else if diff > committed[i]+unknown[i] {
+			v.mismatches++
+			v.unexpected += diff - committed[i] - unknown[i]
+		} else {
+			v.applied += diff - committed[i]
+		}
+	}
+	return v
+}
+
+// Check verifies that the table has --hot-rows rows.
+func (t *HotRowsBench) Check(ctx context.Context) error {
+	table := t.hotTableName()
+	fmt.Printf("Checking table '%s'...\n", table)
+
+	counters, err := t.readCounters(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check table '%s': %w", table, err)
+	}
+	if len(counters) != t.opts.HotRows {
+		return fmt.Errorf("table '%s' has %d rows, expected %d", table, len(counters), t.opts.HotRows)
+	}
+	fmt.Println("All tables are consistent")
+
+	return nil
+}
+
+// Cleanup drops the table created by prepare.
+func (t *HotRowsBench) Cleanup(ctx context.Context) error {
+	table := t.hotTableName()
+	fmt.Printf("Dropping table '%s'...\n", table)
+
+	if t.opts.DBDriver == DBDriverSpanner {
+		// Spanner does not support IF EXISTS
+		var count int64
+		err := t.db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM information_schema.tables WHERE table_name = '%s'", table)).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			_, err = t.db.ExecContext(ctx, fmt.Sprintf("DROP TABLE %s", table))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	_, err := t.db.ExecContext(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s", table))
+	if err != nil {
+		return err
+	}
+	_, err = t.db.ExecContext(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s", t.heartbeatTable()))
+	return err
+}
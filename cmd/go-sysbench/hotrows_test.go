@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/googleapis/go-sql-spanner"
+	"github.com/lib/pq"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{&mysql.MySQLError{Number: 1213}, "1213"},
+		{fmt.Errorf("wrapped: %w", &mysql.MySQLError{Number: 1205}), "1205"},
+		{&pq.Error{Code: "40001"}, "40001"},
+		{spannerdriver.ErrAbortedDueToConcurrentModification, "Aborted"},
+		{status.Error(codes.DeadlineExceeded, "deadline"), "DeadlineExceeded"},
+		{errors.New("unknown"), "other"},
+	}
+
+	for _, tt := range tests {
+		if actual := errorCode(tt.err); actual != tt.expected {
+			t.Errorf("Expected %s for %v, got %s", tt.expected, tt.err, actual)
+		}
+	}
+}
+
+func TestOutcomeUnknown(t *testing.T) {
+	known := []error{
+		&mysql.MySQLError{Number: 1213},
+		&pq.Error{Code: "40001"},
+		sql.ErrTxDone,
+		spannerdriver.ErrAbortedDueToConcurrentModification,
+		status.Error(codes.Aborted, "aborted"),
+	}
+	for _, err := range known {
+		if outcomeUnknown(err) {
+			t.Errorf("Expected the outcome of %v to be known", err)
+		}
+	}
+
+	unknown := []error{
+		mysql.ErrInvalidConn,
+		context.Canceled,
+		status.Error(codes.Unavailable, "unavailable"),
+	}
+	for _, err := range unknown {
+		if !outcomeUnknown(err) {
+			t.Errorf("Expected the outcome of %v to be unknown", err)
+		}
+	}
+}
+
+func TestVerifyCounters(t *testing.T) {
+	before := []int64{10, 20, 30, 40}
+	after := []int64{15, 22, 30, 45}
+	committed := []int64{5, 2, 1, 3}
+	unknown := []int64{0, 1, 0, 1}
+
+	// row 2 applied none of 1 unknown commit, row 3 lost an increment, row 4 has one more than explained
+	v := verifyCounters(before, after, committed, unknown)
+	expected := counterVerification{mismatches: 2, lost: 1, unexpected: 1, applied: 0}
+	if v != expected {
+		t.Errorf("Expected %+v, got %+v", expected, v)
+	}
+
+	after = []int64{15, 23, 31, 44}
+	v = verifyCounters(before, after, committed, unknown)
+	expected = counterVerification{applied: 2}
+	if v != expected {
+		t.Errorf("Expected %+v, got %+v", expected, v)
+	}
+}
+
+func TestHotRowsAtomicEvent(t *testing.T) {
+	opts := &BenchmarkOpts{}
+	opts.Tables = 1
+	opts.HotRows = 1
+	opts.HotPattern = OptHotPatternAtomic
+
+	forEachFakeCase(t, opts, func(o *OLTPBench, c fakeCase) {
+		h := &HotRowsBench{OLTPBench: o, increments: make([]atomic.Int64, 1), unknown: make([]atomic.Int64, 1)}
+
+		stmts := map[string]string{hotUpdate: "UPDATE sbtest_hot SET counter=counter+1 WHERE id=?"}
+		if err := h.prepareStmts(context.Background(), map[int]map[string]string{hotTableNum: stmts}, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		numReads, numWrites, numOthers, err := h.hotEvent(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// an update which changes no rows is counted as an other query
+		if c.rowsAffected == 0 {
+			assertCounts(t, c, 0, 0, 1, numReads, numWrites, numOthers)
+		} else {
+			assertCounts(t, c, 0, 1, 0, numReads, numWrites, numOthers)
+		}
+		// and does not increment the counter
+		if increments := h.increments[0].Load(); increments != c.rowsAffected {
+			t.Errorf("%s: expected %d committed increments, got %d", c, c.rowsAffected, increments)
+		}
+	})
+}
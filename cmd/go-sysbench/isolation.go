@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+const (
+	OptIsolationDefault         = "default"
+	OptIsolationReadUncommitted = "read-uncommitted"
+	OptIsolationReadCommitted   = "read-committed"
+	OptIsolationRepeatableRead  = "repeatable-read"
+	OptIsolationSerializable    = "serializable"
+)
+
+var isolationLevels map[string]sql.IsolationLevel = map[string]sql.IsolationLevel{
+	OptIsolationDefault:         sql.LevelDefault,
+	OptIsolationReadUncommitted: sql.LevelReadUncommitted,
+	OptIsolationReadCommitted:   sql.LevelReadCommitted,
+	OptIsolationRepeatableRead:  sql.LevelRepeatableRead,
+	OptIsolationSerializable:    sql.LevelSerializable,
+}
+
+// txOptions returns the options of transactions which events begin.
+func (o *OLTPBench) txOptions(readOnly bool) *sql.TxOptions {
+	return &sql.TxOptions{Isolation: isolationLevels[o.opts.Isolation], ReadOnly: readOnly}
+}
+
+// printIsolation prints the isolation level which the server reports in a transaction begun with --isolation.
+func (o *OLTPBench) printIsolation(ctx context.Context) error {
+	var level string
+
+	if o.opts.DBDriver == DBDriverSpanner || o.opts.SkipTrx == OptOn {
+		return nil
+	}
+
+	tx, err := o.beginTx(ctx, o.db, o.txOptions(false))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if o.opts.DBDriver == DBDriverMySQL {
+		err = tx.QueryRowContext(ctx, "SELECT @@transaction_isolation").Scan(&level)
+		if err != nil {
+			// before MySQL 5.7.20
+			err = tx.QueryRowContext(ctx, "SELECT @@tx_isolation").Scan(&level)
+		}
+	} else {
+		err = tx.QueryRowContext(ctx, "SHOW transaction_isolation").Scan(&level)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get transaction isolation level: %w", err)
+	}
+
+	if o.opts.Isolation == OptIsolationDefault {
+		fmt.Printf("Transaction isolation level: %s (server default)\n", level)
+	} else {
+		fmt.Printf("Transaction isolation level: %s\n", level)
+	}
+	return nil
+}
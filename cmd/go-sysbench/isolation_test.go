@@ -0,0 +1,26 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestTxOptions(t *testing.T) {
+	opts := &BenchmarkOpts{}
+	o := newOLTPBench(opts, rwModeReadWrite)
+
+	expected := map[string]sql.IsolationLevel{
+		OptIsolationDefault:         sql.LevelDefault,
+		OptIsolationReadUncommitted: sql.LevelReadUncommitted,
+		OptIsolationReadCommitted:   sql.LevelReadCommitted,
+		OptIsolationRepeatableRead:  sql.LevelRepeatableRead,
+		OptIsolationSerializable:    sql.LevelSerializable,
+	}
+	for isolation, level := range expected {
+		opts.Isolation = isolation
+		txOpt := o.txOptions(true)
+		if txOpt.Isolation != level || !txOpt.ReadOnly {
+			t.Errorf("Expected %s read only for --isolation=%s, got %s read only=%t", level, isolation, txOpt.Isolation, txOpt.ReadOnly)
+		}
+	}
+}
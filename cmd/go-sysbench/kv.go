@@ -0,0 +1,481 @@
+package main
+
+import (
+	"context"
+	crand "crypto/rand"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"github.com/googleapis/go-sql-spanner"
+)
+
+const (
+	NameKV = "kv"
+
+	kvGet    = "get"
+	kvPut    = "put"
+	kvDelete = "delete"
+	kvScan   = "scan"
+
+	// upper limit of the size of a multi-row INSERT statement or a mutation batch in prepare
+	kvPrepareBatchBytes = 1024 * 1024
+)
+
+var kvStmtsMySQL map[string]string = map[string]string{
+	kvGet:    "SELECT v FROM %s WHERE k=?",
+	kvPut:    "REPLACE INTO %s (k, v) VALUES (?, ?)",
+	kvDelete: "DELETE FROM %s WHERE k=?",
+	kvScan:   "SELECT k, v FROM %s WHERE k BETWEEN ? AND ?",
+}
+
+var kvStmtsPgSQL map[string]string = map[string]string{
+	kvGet:    "SELECT v FROM %s WHERE k=$1",
+	kvPut:    "INSERT INTO %s (k, v) VALUES ($1, $2) ON CONFLICT (k) DO UPDATE SET v=EXCLUDED.v",
+	kvDelete: "DELETE FROM %s WHERE k=$1",
+	kvScan:   "SELECT k, v FROM %s WHERE k BETWEEN $1 AND $2",
+}
+
+var kvStmtsSpanner map[string]string = map[string]string{
+	kvGet:    "SELECT v FROM %s WHERE k=?",
+	kvPut:    "INSERT OR UPDATE INTO %s (k, v) VALUES (?, ?)",
+	kvDelete: "DELETE FROM %s WHERE k=?",
+	kvScan:   "SELECT k, v FROM %s WHERE k BETWEEN ? AND ?",
+}
+
+type (
+	KVOpts struct {
+		KVValueSize   string `long:"kv-value-size" description:"size of values in bytes, fixed or MIN-MAX for a uniform distribution. K, M suffixes are accepted" default:"1K"`
+		KVGetRatio    int    `long:"kv-get-ratio" description:"weight of get operations" default:"80"`
+		KVPutRatio    int    `long:"kv-put-ratio" description:"weight of put operations, which insert or overwrite a key" default:"20"`
+		KVDeleteRatio int    `long:"kv-delete-ratio" description:"weight of delete operations" default:"0"`
+		KVScanRatio   int    `long:"kv-scan-ratio" description:"weight of scan operations" default:"0"`
+		KVScanSize    int    `long:"kv-scan-size" description:"number of keys in a scan operation" default:"100"`
+		KVBatch       int    `long:"kv-batch" description:"number of operations per transaction. 1 or --skip-trx runs each operation in autocommit, so --isolation requires > 1" default:"1"`
+	}
+
+	// KVBench is a key-value workload on (k, v) tables, sharing hosts and options with OLTPBench.
+	// Keys are from 1 to --table_size of each table.
+	KVBench struct {
+		*OLTPBench
+
+		valueMin int
+		valueMax int
+		values   []byte // random bytes which values are sliced from
+
+		ops []kvOp
+	}
+
+	kvOp struct {
+		name   string
+		weight int
+	}
+)
+
+func newKVBench(option *BenchmarkOpts) *KVBench {
+	return &KVBench{OLTPBench: newOLTPBench(option, rwModeReadWrite)}
+}
+
+// parseValueSize parses --kv-value-size.
+func parseValueSize(size string) (int, int, error) {
+	minStr, maxStr, found := strings.Cut(size, "-")
+	if !found {
+		maxStr = minStr
+	}
+
+	minSize, err := parseSize(minStr)
+	if err != nil {
+		return 0, 0, err
+	}
+	maxSize, err := parseSize(maxStr)
+	if err != nil {
+		return 0, 0, err
+	}
+	if minSize > maxSize {
+		return 0, 0, fmt.Errorf("invalid value size: %s", size)
+	}
+	return int(minSize), int(maxSize), nil
+}
+
+func (k *KVBench) Init(ctx context.Context) error {
+	var err error
+
+	k.valueMin, k.valueMax, err = parseValueSize(k.opts.KVValueSize)
+	if err != nil {
+		return err
+	}
+
+	k.ops = nil
+	for _, op := range []kvOp{
+		{kvGet, k.opts.KVGetRatio},
+		{kvPut, k.opts.KVPutRatio},
+		{kvDelete, k.opts.KVDeleteRatio},
+		{kvScan, k.opts.KVScanRatio},
+	} {
+		if op.weight < 0 {
+			return fmt.Errorf("Invalid value for kv-%s-ratio: %d", op.name, op.weight)
+		}
+		if op.weight > 0 {
+			k.ops = append(k.ops, op)
+		}
+	}
+	if len(k.ops) == 0 {
+		return fmt.Errorf("at least one of --kv-get-ratio, --kv-put-ratio, --kv-delete-ratio and --kv-scan-ratio should be > 0")
+	}
+	if k.opts.KVBatch < 1 {
+		return fmt.Errorf("Invalid value for kv-batch: %d", k.opts.KVBatch)
+	}
+	if k.opts.KVScanSize < 1 {
+		return fmt.Errorf("Invalid value for kv-scan-size: %d", k.opts.KVScanSize)
+	}
+	if k.opts.ReadHosts != "" {
+		return fmt.Errorf("--read-hosts is not supported by '%s' test", NameKV)
+	}
+	// a single operation runs in autocommit, where the isolation level is not set
+	if k.opts.KVBatch == 1 && k.opts.Isolation != OptIsolationDefault {
+		return fmt.Errorf("--isolation requires --kv-batch > 1, since a single operation runs in autocommit")
+	}
+
+	return k.OLTPBench.Init(ctx)
+}
+
+func (k *KVBench) kvTableName(tableNum int) string {
+	return fmt.Sprintf("%s_kv%d", k.opts.TablePrefix, tableNum)
+}
+
+func (k *KVBench) Prepare(ctx context.Context) error {
+	var query string
+
+	for tableNum := 1; tableNum <= k.opts.Tables; tableNum++ {
+		table := k.kvTableName(tableNum)
+
+		fmt.Printf("Creating table '%s'...\n", table)
+		if k.opts.DBDriver == DBDriverSpanner && k.opts.SpannerDialect == OptSpannerDialectPostgreSQL {
+			query = fmt.Sprintf("CREATE TABLE %s (k BIGINT NOT NULL, v BYTEA NOT NULL, PRIMARY KEY (k)) %s", table, k.opts.CreateTableOpts)
+		} else if k.opts.DBDriver == DBDriverSpanner {
+			query = fmt.Sprintf("CREATE TABLE %s (k INT64 NOT NULL, v BYTES(MAX) NOT NULL) PRIMARY KEY (k) %s", table, k.opts.CreateTableOpts)
+		} else if k.opts.DBDriver == DBDriverPgSQL {
+			query = fmt.Sprintf("CREATE TABLE %s (k BIGINT NOT NULL, v BYTEA NOT NULL, PRIMARY KEY (k)) %s", table, k.opts.CreateTableOpts)
+		} else {
+			query = fmt.Sprintf("CREATE TABLE %s (k BIGINT NOT NULL, v LONGBLOB NOT NULL, PRIMARY KEY (k)) /*! ENGINE = %s */ %s", table, k.opts.MySQLEngine, k.opts.CreateTableOpts)
+		}
+
+		_, err := k.db.ExecContext(ctx, query)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Inserting %d records into '%s'\n", k.opts.TableSize, table)
+		err = k.insertKVRows(ctx, table)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertKVRows inserts keys from 1 to --table_size in batches of up to kvPrepareBatchBytes.
+// Values are passed as parameters since they are binary.
+func (k *KVBench) insertKVRows(ctx context.Context, table string) error {
+	batchRows := max(1, min(500, kvPrepareBatchBytes/max(1, k.valueMax)))
+
+	for first := 1; first <= k.opts.TableSize; first += batchRows {
+		last := min(first+batchRows-1, k.opts.TableSize)
+
+		if k.opts.DBDriver == DBDriverSpanner {
+			mutations := make([]*spanner.Mutation, 0, last-first+1)
+			for key := first; key <= last; key++ {
+				mutations = append(mutations, spanner.Insert(table, []string{"k", "v"}, []any{int64(key), k.randValue()}))
+			}
+
+			conn, err := k.db.Conn(ctx)
+			if err != nil {
+				return err
+			}
+			err = conn.Raw(func(driverConn any) error {
+				_, err := driverConn.(spannerdriver.SpannerConn).Apply(ctx, mutations)
+				return err
+			})
+			conn.Close()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		var placeholders []string
+		var args []any
+		for key := first; key <= last; key++ {
+			placeholders = append(placeholders, "(?, ?)")
+			args = append(args, key, k.randValue())
+		}
+
+		query := fmt.Sprintf("INSERT INTO %s (k, v) VALUES %s", table, strings.Join(placeholders, ","))
+		if k.opts.DBDriver == DBDriverPgSQL {
+			query = rebindPgSQL(query)
+		}
+		_, err := k.db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// randValue returns a value of random size. The bytes are random so that compression does not shrink them.
+func (k *KVBench) randValue() []byte {
+	if k.values == nil {
+		k.values = make([]byte, k.valueMax*2)
+		_, _ = crand.Read(k.values)
+	}
+
+	size := sbRand(k.valueMin, k.valueMax)
+	offset := rand.Intn(len(k.values) - size + 1)
+	return k.values[offset : offset+size]
+}
+
+func (k *KVBench) PreEvent(ctx context.Context) error {
+	var stmtTemplates map[string]string
+	var err error
+
+	if k.opts.DBDriver == DBDriverMySQL {
+		stmtTemplates = kvStmtsMySQL
+	} else if k.opts.DBDriver == DBDriverPgSQL {
+		stmtTemplates = kvStmtsPgSQL
+	} else if k.opts.DBDriver == DBDriverSpanner && k.opts.SpannerDialect == OptSpannerDialectPostgreSQL {
+		stmtTemplates = kvStmtsPgSQL
+	} else if k.opts.DBDriver == DBDriverSpanner {
+		stmtTemplates = kvStmtsSpanner
+	} else {
+		panic("Unexpected driver")
+	}
+
+	if k.opts.DBDriver == DBDriverSpanner {
+		err = k.setupSpanner()
+		if err != nil {
+			return err
+		}
+	}
+
+	stmts := make(map[int]map[string]string)
+	for tableNum := 1; tableNum <= k.opts.Tables; tableNum++ {
+		table := k.kvTableName(tableNum)
+
+		if k.opts.TableCheck == OptOn {
+			var maxKey sql.NullInt64
+			err = k.db.QueryRowContext(ctx, fmt.Sprintf("SELECT MAX(k) FROM %s", table)).Scan(&maxKey)
+			if err != nil {
+				return fmt.Errorf("table '%s' is not accessible. run prepare with the same --tables and --table-prefix first: %w", table, err)
+			}
+			if !maxKey.Valid {
+				return fmt.Errorf("table '%s' is empty. run prepare first", table)
+			}
+			if maxKey.Int64 > int64(k.opts.TableSize) {
+				return fmt.Errorf("max key of table '%s' is %d, which exceeds --table_size=%d. prepare and run should use the same --table_size", table, maxKey.Int64, k.opts.TableSize)
+			}
+		}
+
+		stmts[tableNum] = make(map[string]string)
+		for name, stmt := range stmtTemplates {
+			stmts[tableNum][name] = fmt.Sprintf(stmt, table)
+		}
+	}
+
+	err = k.prepareStmts(ctx, stmts, nil)
+	if err != nil {
+		return err
+	}
+
+	err = k.printIsolation(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(k.lagHosts) > 0 {
+		err = k.createHeartbeat(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Value size: %d-%d bytes\n", k.valueMin, k.valueMax)
+	fmt.Printf("Operations per transaction: %d\n\n", k.opts.KVBatch)
+
+	// generate the random bytes before threads share them
+	k.randValue()
+
+	if k.opts.ServerMetrics != "" {
+		err = k.startServerMetrics(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (k *KVBench) Event(ctx context.Context) (numReads, numWrites, numOthers, numIgnoredErros uint64, err error) {
+	return k.runEvent(ctx, k.kvEvent)
+}
+
+// kvEvent runs --kv-batch operations on a table through the executor of --db-ps-mode. A single operation runs in autocommit.
+func (k *KVBench) kvEvent(ctx context.Context) (numReads, numWrites, numOthers uint64, err error) {
+	var inTx bool
+
+	tableNum := k.getRandTableNum()
+
+	e, err := k.executorFor(ctx, k.hostFor(ctx))
+	if err != nil {
+		return numReads, numWrites, numOthers, err
+	}
+	defer e.release()
+
+	if k.opts.KVBatch > 1 && k.opts.SkipTrx == OptOff {
+		err = e.begin(ctx, k.txOptions(false))
+		if err != nil {
+			return numReads, numWrites, numOthers, err
+		}
+		inTx = true
+		numOthers += 1
+	}
+
+	for i := 0; i < k.opts.KVBatch; i++ {
+		var changed bool
+
+		key := sbRand(1, k.opts.TableSize)
+
+		switch op := k.pickOp(sbRand(1, k.totalWeight())); op {
+		case kvGet:
+			err = queryAll(e.query(ctx, tableNum, kvGet, key))
+			numReads += 1
+		case kvScan:
+			err = queryAll(e.query(ctx, tableNum, kvScan, key, key+k.opts.KVScanSize-1))
+			numReads += 1
+		case kvPut, kvDelete:
+			args := []any{key}
+			if op == kvPut {
+				args = append(args, k.randValue())
+			}
+			// a delete of a deleted key changes no rows
+			changed, err = execCounted(ctx, e, tableNum, op, args...)
+			if changed {
+				numWrites += 1
+			} else if err == nil {
+				numOthers += 1
+			}
+		}
+		if err != nil {
+			e.rollback()
+			return numReads, numWrites, numOthers, err
+		}
+	}
+
+	if inTx {
+		err = e.commit(ctx)
+		if err != nil {
+			return numReads, numWrites, numOthers, err
+		}
+		numOthers += 1
+	}
+	return numReads, numWrites, numOthers, nil
+}
+
+func (k *KVBench) totalWeight() int {
+	var total int
+	for _, op := range k.ops {
+		total += op.weight
+	}
+	return total
+}
+
+// pickOp returns the operation for a random number from 1 to totalWeight().
+func (k *KVBench) pickOp(n int) string {
+	for _, op := range k.ops {
+		if n <= op.weight {
+			return op.name
+		}
+		n -= op.weight
+	}
+	return k.ops[len(k.ops)-1].name
+}
+
+// Check verifies that keys are within --table_size and values are within --kv-value-size.
+func (k *KVBench) Check(ctx context.Context) error {
+	var failed int
+
+	for tableNum := 1; tableNum <= k.opts.Tables; tableNum++ {
+		table := k.kvTableName(tableNum)
+		fmt.Printf("Checking table '%s'...\n", table)
+
+		var count, outOfRange, malformed int64
+		err := k.db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s", table)).Scan(&count)
+		if err != nil {
+			return fmt.Errorf("failed to check table '%s': %w", table, err)
+		}
+		err = k.db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE k < 1 OR k > %d", table, k.opts.TableSize)).Scan(&outOfRange)
+		if err != nil {
+			return fmt.Errorf("failed to check table '%s': %w", table, err)
+		}
+		err = k.db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE LENGTH(v) < %d OR LENGTH(v) > %d", table, k.valueMin, k.valueMax)).Scan(&malformed)
+		if err != nil {
+			return fmt.Errorf("failed to check table '%s': %w", table, err)
+		}
+
+		fmt.Printf("    %d keys\n", count)
+		if outOfRange > 0 {
+			fmt.Printf("    %d keys are out of 1 to %d\n", outOfRange, k.opts.TableSize)
+		}
+		if malformed > 0 {
+			fmt.Printf("    %d values are out of %d to %d bytes\n", malformed, k.valueMin, k.valueMax)
+		}
+		if outOfRange > 0 || malformed > 0 {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d tables are inconsistent with --table_size=%d --kv-value-size=%s", failed, k.opts.Tables, k.opts.TableSize, k.opts.KVValueSize)
+	}
+	fmt.Println("All tables are consistent")
+
+	return nil
+}
+
+// Cleanup drops the tables created by prepare.
+func (k *KVBench) Cleanup(ctx context.Context) error {
+	for tableNum := 1; tableNum <= k.opts.Tables; tableNum++ {
+		table := k.kvTableName(tableNum)
+		fmt.Printf("Dropping table '%s'...\n", table)
+
+		if k.opts.DBDriver == DBDriverSpanner {
+			// Spanner does not support IF EXISTS
+			var count int64
+			err := k.db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM information_schema.tables WHERE table_name = '%s'", table)).Scan(&count)
+			if err != nil {
+				return err
+			}
+			if count == 0 {
+				continue
+			}
+			_, err = k.db.ExecContext(ctx, fmt.Sprintf("DROP TABLE %s", table))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		_, err := k.db.ExecContext(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s", table))
+		if err != nil {
+			return err
+		}
+	}
+
+	if k.opts.DBDriver != DBDriverSpanner {
+		_, err := k.db.ExecContext(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s", k.heartbeatTable()))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
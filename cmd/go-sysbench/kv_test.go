@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestParseValueSize(t *testing.T) {
+	valid := map[string][2]int{
+		"100":     {100, 100},
+		"1K":      {1024, 1024},
+		"100-200": {100, 200},
+		"1K-1M":   {1024, 1024 * 1024},
+		"0-16":    {0, 16},
+	}
+	for input, expected := range valid {
+		minSize, maxSize, err := parseValueSize(input)
+		if err != nil {
+			t.Errorf("Expected %s to be valid, got %s", input, err)
+		}
+		if minSize != expected[0] || maxSize != expected[1] {
+			t.Errorf("Expected %d-%d for %s, got %d-%d", expected[0], expected[1], input, minSize, maxSize)
+		}
+	}
+
+	for _, input := range []string{"", "-", "200-100", "1K-", "a-b"} {
+		if _, _, err := parseValueSize(input); err == nil {
+			t.Errorf("Expected %q to be invalid", input)
+		}
+	}
+}
+
+func TestKVPickOp(t *testing.T) {
+	k := &KVBench{ops: []kvOp{{kvGet, 3}, {kvPut, 1}}}
+
+	counts := make(map[string]int)
+	for n := 1; n <= k.totalWeight(); n++ {
+		counts[k.pickOp(n)]++
+	}
+	if counts[kvGet] != 3 || counts[kvPut] != 1 {
+		t.Errorf("Expected 3 gets and 1 put, got %v", counts)
+	}
+}
+
+func TestKVInitIsolation(t *testing.T) {
+	opts := &BenchmarkOpts{}
+	opts.KVValueSize = "16"
+	opts.KVGetRatio = 1
+	opts.KVBatch = 1
+	opts.KVScanSize = 1
+	opts.Isolation = OptIsolationReadCommitted
+
+	// a single operation runs in autocommit
+	if err := newKVBench(opts).Init(context.Background()); err == nil {
+		t.Errorf("Expected --isolation with --kv-batch=1 to be invalid")
+	}
+}
+
+func TestKVEvent(t *testing.T) {
+	opts := &BenchmarkOpts{}
+	opts.Tables = 1
+	opts.TableSize = 100
+	opts.KVBatch = 4
+
+	forEachFakeCase(t, opts, func(o *OLTPBench, c fakeCase) {
+		k := &KVBench{OLTPBench: o, ops: []kvOp{{kvPut, 1}}, valueMin: 16, valueMax: 16}
+
+		stmts := map[int]map[string]string{1: {}}
+		for name, stmt := range kvStmtsMySQL {
+			stmts[1][name] = fmt.Sprintf(stmt, k.kvTableName(1))
+		}
+		if err := k.prepareStmts(context.Background(), stmts, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		numReads, numWrites, numOthers, err := k.kvEvent(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// BEGIN and COMMIT, and puts which change no rows
+		if c.rowsAffected == 0 {
+			assertCounts(t, c, 0, 0, 6, numReads, numWrites, numOthers)
+		} else {
+			assertCounts(t, c, 0, 4, 2, numReads, numWrites, numOthers)
+		}
+
+		// statements run through the executor of --db-ps-mode
+		prepared := numPrepared("REPLACE INTO")
+		if c.psMode == OptDBPreparedStmtDisable && prepared != 4 {
+			t.Errorf("Expected the statement text to be sent in each put, got %d prepares", prepared)
+		} else if c.psMode != OptDBPreparedStmtDisable && prepared != 1 {
+			t.Errorf("%s: expected the statement to be prepared once, got %d prepares", c, prepared)
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// lagStats aggregates replication lag of all the replicas in a report interval.
+type lagStats struct {
+	mu    sync.Mutex
+	max   time.Duration
+	sum   time.Duration
+	count int
+}
+
+func (s *lagStats) add(lag time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.max = max(s.max, lag)
+	s.sum += lag
+	s.count++
+}
+
+// reset returns max and avg lag, and clears them.
+func (s *lagStats) reset() (time.Duration, time.Duration, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	maxLag, count := s.max, s.count
+	var avgLag time.Duration
+	if count > 0 {
+		avgLag = s.sum / time.Duration(count)
+	}
+	s.max, s.sum, s.count = 0, 0, 0
+
+	return maxLag, avgLag, count
+}
+
+func (o *OLTPBench) heartbeatTable() string {
+	return o.opts.TablePrefix + "_heartbeat"
+}
+
+// createHeartbeat creates the heartbeat table on the primary. It is replicated to --lag-hosts.
+func (o *OLTPBench) createHeartbeat(ctx context.Context) error {
+	if o.opts.LagInterval < 1 {
+		return fmt.Errorf("--lag-interval should be >= 1")
+	}
+
+	_, err := o.db.ExecContext(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (id INT NOT NULL PRIMARY KEY, ts BIGINT NOT NULL)", o.heartbeatTable()))
+	if err != nil {
+		return fmt.Errorf("failed to create heartbeat table: %w", err)
+	}
+	return o.writeHeartbeat(ctx)
+}
+
+// writeHeartbeat stores the current time in nanoseconds on the primary.
+func (o *OLTPBench) writeHeartbeat(ctx context.Context) error {
+	var query string
+	if o.opts.DBDriver == DBDriverPgSQL {
+		query = fmt.Sprintf("INSERT INTO %s (id, ts) VALUES (1, $1) ON CONFLICT (id) DO UPDATE SET ts = EXCLUDED.ts", o.heartbeatTable())
+	} else {
+		query = fmt.Sprintf("REPLACE INTO %s (id, ts) VALUES (1, ?)", o.heartbeatTable())
+	}
+
+	_, err := o.db.ExecContext(ctx, query, time.Now().UnixNano())
+	return err
+}
+
+// Monitor measures replication lag of --lag-hosts in the same way as pt-heartbeat.
+// The heartbeat is written on the primary, and the lag of each replica is the age of the heartbeat read on it.
+// Both timestamps are taken on this client, so clock skew between servers does not matter.
+func (o *OLTPBench) Monitor(ctx context.Context) {
+	if len(o.lagHosts) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(o.opts.LagInterval) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := o.writeHeartbeat(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				fmt.Printf("failed to write heartbeat: %s\n", err)
+			}
+			continue
+		}
+
+		for _, r := range o.lagHosts {
+			var ts int64
+			err = r.db.QueryRowContext(ctx, fmt.Sprintf("SELECT ts FROM %s WHERE id=1", o.heartbeatTable())).Scan(&ts)
+			if err != nil {
+				// the heartbeat table may not be replicated yet
+				continue
+			}
+
+			lag := time.Since(time.Unix(0, ts))
+			r.record(lag)
+			o.lagInterval.add(lag)
+		}
+	}
+}
+
+// IntervalReport returns replication lag and server metrics columns for the intermediate report.
+func (o *OLTPBench) IntervalReport() string {
+	return o.intervalLag() + o.intervalServerMetrics()
+}
+
+// intervalLag returns max/avg replication lag in the interval.
+func (o *OLTPBench) intervalLag() string {
+	if len(o.lagHosts) == 0 {
+		return ""
+	}
+
+	maxLag, avgLag, count := o.lagInterval.reset()
+	if count == 0 {
+		return " lag (ms,max/avg): N/A"
+	}
+	return fmt.Sprintf(" lag (ms,max/avg): %4.2f/%4.2f", float64(maxLag)/nano2mili, float64(avgLag)/nano2mili)
+}
+
+// reportLag prints max/avg replication lag of each replica.
+func (o *OLTPBench) reportLag() {
+	if len(o.lagHosts) == 0 {
+		return
+	}
+
+	fmt.Println("\nReplication lag (ms):")
+	for _, r := range o.lagHosts {
+		samples := r.events.Load()
+		if samples == 0 {
+			fmt.Printf("    %s\n        no heartbeat was read\n", r.addr)
+			continue
+		}
+
+		fmt.Printf("    %s\n"+
+			"        samples: %35d\n"+
+			"        max: %39.2f\n"+
+			"        avg: %39.2f\n",
+			r.addr, samples,
+			float64(r.latencyNanoMax.Load())/nano2mili,
+			float64(r.latencyNanoSum.Load())/nano2mili/float64(samples))
+	}
+}
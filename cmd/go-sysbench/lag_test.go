@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLagStats(t *testing.T) {
+	var s lagStats
+
+	s.add(10 * time.Millisecond)
+	s.add(30 * time.Millisecond)
+
+	maxLag, avgLag, count := s.reset()
+	if maxLag != 30*time.Millisecond || avgLag != 20*time.Millisecond || count != 2 {
+		t.Errorf("Expected 30ms/20ms/2, got %s/%s/%d", maxLag, avgLag, count)
+	}
+
+	if _, _, count = s.reset(); count != 0 {
+		t.Errorf("Expected stats to be cleared, got %d samples", count)
+	}
+}
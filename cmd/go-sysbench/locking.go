@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+)
+
+const (
+	OptLockingReadsOff       = "off"
+	OptLockingReadsForUpdate = "for-update"
+	OptLockingReadsForShare  = "for-share"
+)
+
+// validateLockingReads checks that --locking-reads is used where point selects run in a read-write transaction.
+func (o *OLTPBench) validateLockingReads() error {
+	if o.opts.LockingReads == OptLockingReadsOff {
+		return nil
+	}
+	if o.rwMode != rwModeReadWrite {
+		return fmt.Errorf("--locking-reads is applicable only to %s", NameOLTPReadWrite)
+	}
+	if o.opts.ReadHosts != "" || o.opts.SkipTrx == OptOn {
+		return fmt.Errorf("--locking-reads can not be used with --read-hosts or --skip-trx, which run SELECT statements outside transaction")
+	}
+	return nil
+}
+
+// lockingRead returns the SELECT statement which locks the rows it reads, in the syntax of the driver.
+// Spanner takes shared locks by reads in read-write transactions, and exclusive locks with the LOCK_SCANNED_RANGES hint.
+// https://cloud.google.com/spanner/docs/reference/standard-sql/query-syntax#statement_hints
+func (o *OLTPBench) lockingRead(stmt string, mode string) string {
+	if mode == OptLockingReadsOff {
+		return stmt
+	}
+
+	switch o.opts.DBDriver {
+	case DBDriverMySQL:
+		if mode == OptLockingReadsForUpdate {
+			return stmt + " FOR UPDATE"
+		}
+		// FOR SHARE is not supported by MySQL 5.7 and MariaDB
+		return stmt + " LOCK IN SHARE MODE"
+	case DBDriverPgSQL:
+		if mode == OptLockingReadsForUpdate {
+			return stmt + " FOR UPDATE"
+		}
+		return stmt + " FOR SHARE"
+	case DBDriverSpanner:
+		if mode == OptLockingReadsForShare {
+			return stmt
+		}
+		if o.opts.SpannerDialect == OptSpannerDialectPostgreSQL {
+			return "/*@ LOCK_SCANNED_RANGES=exclusive */ " + stmt
+		}
+		return "@{LOCK_SCANNED_RANGES=exclusive} " + stmt
+	}
+	return stmt
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestLockingRead(t *testing.T) {
+	opts := &BenchmarkOpts{}
+	o := newOLTPBench(opts, rwModeReadWrite)
+
+	tests := []struct {
+		driver   string
+		dialect  string
+		mode     string
+		expected string
+	}{
+		{DBDriverMySQL, "", OptLockingReadsOff, "SELECT c FROM sbtest1 WHERE id=?"},
+		{DBDriverMySQL, "", OptLockingReadsForUpdate, "SELECT c FROM sbtest1 WHERE id=? FOR UPDATE"},
+		{DBDriverMySQL, "", OptLockingReadsForShare, "SELECT c FROM sbtest1 WHERE id=? LOCK IN SHARE MODE"},
+		{DBDriverPgSQL, "", OptLockingReadsForShare, "SELECT c FROM sbtest1 WHERE id=? FOR SHARE"},
+		{DBDriverSpanner, OptSpannerDialectGoogleSQL, OptLockingReadsForUpdate, "@{LOCK_SCANNED_RANGES=exclusive} SELECT c FROM sbtest1 WHERE id=?"},
+		{DBDriverSpanner, OptSpannerDialectPostgreSQL, OptLockingReadsForUpdate, "/*@ LOCK_SCANNED_RANGES=exclusive */ SELECT c FROM sbtest1 WHERE id=?"},
+		{DBDriverSpanner, OptSpannerDialectGoogleSQL, OptLockingReadsForShare, "SELECT c FROM sbtest1 WHERE id=?"},
+	}
+
+	for _, tt := range tests {
+		opts.DBDriver = tt.driver
+		opts.SpannerDialect = tt.dialect
+
+		if actual := o.lockingRead("SELECT c FROM sbtest1 WHERE id=?", tt.mode); actual != tt.expected {
+			t.Errorf("Expected %q, got %q", tt.expected, actual)
+		}
+	}
+}
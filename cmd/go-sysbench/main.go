@@ -23,7 +23,7 @@ func main() {
 	opts := CmdOpts{}
 
 	parser := flags.NewParser(&opts, flags.HelpFlag|flags.PassDoubleDash)
-	parser.Usage = fmt.Sprintf("[options]... [%s] [prepare|run]", strings.Join(benchmarkNames(), "|"))
+	parser.Usage = fmt.Sprintf("[options]... [%s] [prepare|run|check|cleanup]", strings.Join(benchmarkNames(), "|"))
 
 	args, err := parser.Parse()
 	if err != nil {
@@ -57,6 +57,10 @@ func main() {
 		err = r.Run()
 	} else if command == "prepare" {
 		err = r.Prepare()
+	} else if command == "check" {
+		err = r.Check()
+	} else if command == "cleanup" {
+		err = r.Cleanup()
 	}
 
 	if err != nil {
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/samitani/go-sysbench"
+)
+
+const (
+	NameMemory = "memory"
+
+	OptMemoryScopeGlobal = "global"
+	OptMemoryScopeLocal  = "local"
+
+	OptMemoryOperRead  = "read"
+	OptMemoryOperWrite = "write"
+	OptMemoryOperNone  = "none"
+
+	OptMemoryAccessModeSeq = "seq"
+	OptMemoryAccessModeRnd = "rnd"
+
+	// memory is accessed by words of this size
+	memoryWordSize = 8
+
+	mebibyte = 1024 * 1024
+)
+
+type (
+	MemoryOpts struct {
+		MemoryBlockSize  string `long:"memory-block-size" description:"size of memory block for test" default:"1K"`
+		MemoryTotalSize  string `long:"memory-total-size" description:"total size of data to transfer" default:"100G"`
+		MemoryScope      string `long:"memory-scope" choice:"global" choice:"local" description:"memory access scope" default:"global"`                 //nolint:staticcheck
+		MemoryOper       string `long:"memory-oper" choice:"read" choice:"write" choice:"none" description:"type of memory operations" default:"write"` //nolint:staticcheck
+		MemoryAccessMode string `long:"memory-access-mode" choice:"seq" choice:"rnd" description:"memory access mode" default:"seq"`                    //nolint:staticcheck
+	}
+
+	// MemoryBench is equivalent to sysbench memory. Each event reads or writes a block of memory.
+	// https://github.com/akopytov/sysbench/blob/1.0.20/src/tests/memory/sb_memory.c
+	MemoryBench struct {
+		opts *MemoryOpts
+
+		blockSize int64
+		totalSize int64
+
+		globalBuf    []uint64
+		localBufs    sync.Map // thread id -> []uint64
+		transferred  atomic.Int64
+		readChecksum atomic.Uint64 // keeps reads from being optimized away
+	}
+)
+
+// parseSize parses a size with an optional K, M, G or T suffix in the same way as sysbench.
+func parseSize(size string) (int64, error) {
+	var mult int64 = 1
+
+	s := strings.ToUpper(strings.TrimSpace(size))
+	switch {
+	case strings.HasSuffix(s, "K"):
+		mult = 1024
+	case strings.HasSuffix(s, "M"):
+		mult = 1024 * 1024
+	case strings.HasSuffix(s, "G"):
+		mult = 1024 * 1024 * 1024
+	case strings.HasSuffix(s, "T"):
+		mult = 1024 * 1024 * 1024 * 1024
+	}
+	if mult > 1 {
+		s = s[:len(s)-1]
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size: %s", size)
+	}
+	return n * mult, nil
+}
+
+func newMemoryBench(option *MemoryOpts) *MemoryBench {
+	return &MemoryBench{opts: option}
+}
+
+func (m *MemoryBench) Init(ctx context.Context) error {
+	var err error
+
+	m.blockSize, err = parseSize(m.opts.MemoryBlockSize)
+	if err != nil {
+		return err
+	}
+	if m.blockSize < memoryWordSize || m.blockSize%memoryWordSize != 0 {
+		return fmt.Errorf("--memory-block-size should be a multiple of %d bytes", memoryWordSize)
+	}
+
+	m.totalSize, err = parseSize(m.opts.MemoryTotalSize)
+	if err != nil {
+		return err
+	}
+	// no event runs, and the throughput is divided by zero time
+	if m.totalSize <= 0 {
+		return fmt.Errorf("--memory-total-size should be > 0")
+	}
+
+	return nil
+}
+
+func (m *MemoryBench) Done() error {
+	return nil
+}
+
+func (m *MemoryBench) Prepare(ctx context.Context) error {
+	return fmt.Errorf("'%s' test does not implement the 'prepare' command", NameMemory)
+}
+
+func (m *MemoryBench) PreEvent(ctx context.Context) error {
+	fmt.Printf("Running memory speed test with the following options:\n"+
+		"  block size: %s\n"+
+		"  total size: %s\n"+
+		"  operation: %s\n"+
+		"  scope: %s\n\n",
+		formatSize(m.blockSize, 1024, "KiB"), formatSize(m.totalSize, mebibyte, "MiB"), m.opts.MemoryOper, m.opts.MemoryScope)
+
+	if m.opts.MemoryScope == OptMemoryScopeGlobal {
+		m.globalBuf = make([]uint64, m.blockSize/memoryWordSize)
+	}
+	return nil
+}
+
+// formatSize formats the size in the unit, or in bytes if it is not a multiple of the unit.
+func formatSize(size int64, unit int64, unitName string) string {
+	if size%unit != 0 {
+		return fmt.Sprintf("%dB", size)
+	}
+	return fmt.Sprintf("%d%s", size/unit, unitName)
+}
+
+// buffer returns the memory block which the thread accesses.
+func (m *MemoryBench) buffer(ctx context.Context) []uint64 {
+	if m.opts.MemoryScope == OptMemoryScopeGlobal {
+		return m.globalBuf
+	}
+
+	threadID := sysbench.ThreadID(ctx)
+	buf, ok := m.localBufs.Load(threadID)
+	if !ok {
+		buf = make([]uint64, m.blockSize/memoryWordSize)
+		m.localBufs.Store(threadID, buf)
+	}
+	return buf.([]uint64)
+}
+
+func (m *MemoryBench) Event(ctx context.Context) (numReads, numWrites, numOthers, numIgnoredErros uint64, err error) {
+	if m.transferred.Add(m.blockSize) > m.totalSize {
+		return 0, 0, 0, 0, sysbench.ErrNoMoreEvents
+	}
+
+	buf := m.buffer(ctx)
+	rnd := m.opts.MemoryAccessMode == OptMemoryAccessModeRnd
+
+	// all threads access the global block. sysbench accesses it without synchronization,
+	// which is a data race in Go, so its words are accessed atomically.
+	// atomic loads are plain loads on amd64 and arm64, but atomic stores are slower than plain stores.
+	global := m.opts.MemoryScope == OptMemoryScopeGlobal
+
+	if m.opts.MemoryOper == OptMemoryOperWrite {
+		for i := range buf {
+			if rnd {
+				i = rand.Intn(len(buf))
+			}
+			if global {
+				atomic.StoreUint64(&buf[i], 1)
+			} else {
+				buf[i] = 1
+			}
+		}
+	} else if m.opts.MemoryOper == OptMemoryOperRead {
+		var sum uint64
+		for i := range buf {
+			if rnd {
+				i = rand.Intn(len(buf))
+			}
+			if global {
+				sum += atomic.LoadUint64(&buf[i])
+			} else {
+				sum += buf[i]
+			}
+		}
+		m.readChecksum.Add(sum)
+	}
+
+	return 0, 0, 0, 0, nil
+}
+
+// Summary prints operations and MiB transferred in the same format as sysbench memory.
+func (m *MemoryBench) Summary(result *sysbench.Result) {
+	seconds := result.TotalTime.Seconds()
+	transferred := float64(result.TotalEvents) * float64(m.blockSize) / mebibyte
+
+	fmt.Printf("Total operations: %d (%8.2f per second)\n\n", result.TotalEvents, float64(result.TotalEvents)/seconds)
+	fmt.Printf("%4.2f MiB transferred (%4.2f MiB/sec)\n\n", transferred, transferred/seconds)
+}
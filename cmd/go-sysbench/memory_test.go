@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	valid := map[string]int64{
+		"0":    0,
+		"512":  512,
+		"1K":   1024,
+		"4k":   4096,
+		"16M":  16 * 1024 * 1024,
+		"100G": 100 * 1024 * 1024 * 1024,
+		"1T":   1024 * 1024 * 1024 * 1024,
+	}
+	for input, expected := range valid {
+		size, err := parseSize(input)
+		if err != nil {
+			t.Errorf("Expected %s to be valid, got %s", input, err)
+		}
+		if size != expected {
+			t.Errorf("Expected %d, got %d", expected, size)
+		}
+	}
+
+	for _, input := range []string{"", "K", "1KB", "-1", "1.5G"} {
+		if _, err := parseSize(input); err == nil {
+			t.Errorf("Expected %q to be invalid", input)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		size     int64
+		expected string
+	}{
+		{512, "512B"},
+		{1024, "1KiB"},
+		{1536, "1536B"},
+		{16 * 1024, "16KiB"},
+	}
+	for _, tt := range tests {
+		if actual := formatSize(tt.size, 1024, "KiB"); actual != tt.expected {
+			t.Errorf("Expected %s, got %s", tt.expected, actual)
+		}
+	}
+}
+
+// TestMemoryGlobalScope runs threads on the global block, which the race detector checks.
+func TestMemoryInitTotalSize(t *testing.T) {
+	m := newMemoryBench(&MemoryOpts{MemoryBlockSize: "1K", MemoryTotalSize: "0"})
+	if err := m.Init(context.Background()); err == nil {
+		t.Errorf("Expected --memory-total-size=0 to be invalid")
+	}
+}
+
+func TestMemoryGlobalScope(t *testing.T) {
+	for _, oper := range []string{OptMemoryOperWrite, OptMemoryOperRead} {
+		for _, mode := range []string{OptMemoryAccessModeSeq, OptMemoryAccessModeRnd} {
+			m := newMemoryBench(&MemoryOpts{
+				MemoryBlockSize:  "1K",
+				MemoryTotalSize:  "1M",
+				MemoryScope:      OptMemoryScopeGlobal,
+				MemoryOper:       oper,
+				MemoryAccessMode: mode,
+			})
+			if err := m.Init(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if err := m.PreEvent(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			var wg sync.WaitGroup
+			for i := 0; i < 4; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 16; j++ {
+						if _, _, _, _, err := m.Event(context.Background()); err != nil {
+							t.Error(err)
+							return
+						}
+					}
+				}()
+			}
+			wg.Wait()
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/samitani/go-sysbench"
+)
+
+// startServerMetrics validates --server-metrics and takes the snapshot before events.
+func (o *OLTPBench) startServerMetrics(ctx context.Context) error {
+	for _, name := range strings.Split(o.opts.ServerMetrics, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return fmt.Errorf("invalid --server-metrics: %s", o.opts.ServerMetrics)
+		}
+		o.metricNames = append(o.metricNames, name)
+	}
+
+	snapshot, err := o.sampleServerMetrics(ctx)
+	if err != nil {
+		return err
+	}
+	o.metricsStart = snapshot
+	o.metricsLast = snapshot
+
+	return nil
+}
+
+// sampleServerMetrics returns the current values of --server-metrics summed over all the hosts.
+func (o *OLTPBench) sampleServerMetrics(ctx context.Context) (map[string]float64, error) {
+	snapshot := make(map[string]float64)
+
+	for _, h := range o.hosts {
+		var values map[string]string
+		var err error
+		if o.opts.DBDriver == DBDriverPgSQL {
+			values, err = samplePgSQLStats(ctx, h.db)
+		} else {
+			values, err = sampleMySQLStatus(ctx, h.db)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to sample server metrics on %s: %w", h.addr, err)
+		}
+
+		for _, name := range o.metricNames {
+			value, found := values[strings.ToLower(name)]
+			if !found {
+				return nil, fmt.Errorf("unknown server metric: %s", name)
+			}
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("server metric %s is not a number: %s", name, value)
+			}
+			snapshot[name] += v
+		}
+	}
+	return snapshot, nil
+}
+
+// sampleMySQLStatus returns SHOW GLOBAL STATUS keyed by lower case variable names.
+func sampleMySQLStatus(ctx context.Context, db *sql.DB) (map[string]string, error) {
+	rows, err := db.QueryContext(ctx, "SHOW GLOBAL STATUS")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := make(map[string]string)
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		values[strings.ToLower(name)] = value
+	}
+	return values, rows.Err()
+}
+
+// samplePgSQLStats returns the pg_stat_database row of the current database keyed by column names.
+func samplePgSQLStats(ctx context.Context, db *sql.DB) (map[string]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT * FROM pg_stat_database WHERE datname = current_database()")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if !rows.Next() {
+		return nil, fmt.Errorf("no row in pg_stat_database for the current database")
+	}
+
+	raw := make([]sql.NullString, len(columns))
+	dest := make([]any, len(columns))
+	for i := range raw {
+		dest[i] = &raw[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string)
+	for i, column := range columns {
+		values[strings.ToLower(column)] = raw[i].String
+	}
+	return values, rows.Err()
+}
+
+// formatMetricDeltas formats the deltas of the metrics as name=delta pairs.
+func formatMetricDeltas(names []string, prev, cur map[string]float64) string {
+	var pairs []string
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", name, strconv.FormatFloat(cur[name]-prev[name], 'f', -1, 64)))
+	}
+	return strings.Join(pairs, " ")
+}
+
+// intervalServerMetrics returns the deltas of the server metrics since the last intermediate report.
+func (o *OLTPBench) intervalServerMetrics() string {
+	if o.metricsStart == nil {
+		return ""
+	}
+
+	snapshot, err := o.sampleServerMetrics(context.Background())
+	if err != nil {
+		return " metrics: N/A"
+	}
+	deltas := formatMetricDeltas(o.metricNames, o.metricsLast, snapshot)
+	o.metricsLast = snapshot
+
+	return " metrics: " + deltas
+}
+
+// reportServerMetrics prints the deltas of the server metrics between before and after events.
+func (o *OLTPBench) reportServerMetrics(result *sysbench.Result) {
+	if o.metricsStart == nil || o.metricsEnd == nil {
+		return
+	}
+
+	fmt.Println("\nServer metrics (delta during run):")
+	for _, name := range o.metricNames {
+		delta := o.metricsEnd[name] - o.metricsStart[name]
+		fmt.Printf("    %-37s%-6s (%.2f per sec.)\n", name+":", strconv.FormatFloat(delta, 'f', -1, 64), delta/result.TotalTime.Seconds())
+	}
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestFormatMetricDeltas(t *testing.T) {
+	names := []string{"Innodb_row_lock_waits", "blk_read_time"}
+	prev := map[string]float64{"Innodb_row_lock_waits": 10, "blk_read_time": 1.25}
+	cur := map[string]float64{"Innodb_row_lock_waits": 25, "blk_read_time": 2}
+
+	expected := "Innodb_row_lock_waits=15 blk_read_time=0.75"
+	if actual := formatMetricDeltas(names, prev, cur); actual != expected {
+		t.Errorf("Expected %q, got %q", expected, actual)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"github.com/samitani/go-sysbench"
+)
+
+const NameMutex = "mutex"
+
+type (
+	MutexOpts struct {
+		MutexNum   int `long:"mutex-num" description:"total size of mutex array" default:"4096"`
+		MutexLocks int `long:"mutex-locks" description:"number of mutex locks to do per thread" default:"50000"`
+		MutexLoops int `long:"mutex-loops" description:"number of empty loops to do outside mutex lock" default:"10000"`
+	}
+
+	// mutexSlot is padded so that mutexes do not share a cache line.
+	mutexSlot struct {
+		mu    sync.Mutex
+		value int
+		_     [64]byte
+	}
+
+	// MutexBench is equivalent to sysbench mutex. Each thread runs one event,
+	// which takes random mutexes --mutex-locks times with empty loops in between.
+	// https://github.com/akopytov/sysbench/blob/1.0.20/src/tests/mutex/sb_mutex.c
+	MutexBench struct {
+		opts  *MutexOpts
+		slots []mutexSlot
+		done  sync.Map // thread id -> struct{}
+	}
+)
+
+func newMutexBench(option *MutexOpts) *MutexBench {
+	return &MutexBench{opts: option}
+}
+
+func (m *MutexBench) Init(ctx context.Context) error {
+	if m.opts.MutexNum < 1 {
+		return fmt.Errorf("--mutex-num should be >= 1")
+	}
+	m.slots = make([]mutexSlot, m.opts.MutexNum)
+	return nil
+}
+
+func (m *MutexBench) Done() error {
+	return nil
+}
+
+func (m *MutexBench) Prepare(ctx context.Context) error {
+	return fmt.Errorf("'%s' test does not implement the 'prepare' command", NameMutex)
+}
+
+func (m *MutexBench) PreEvent(ctx context.Context) error {
+	return nil
+}
+
+func (m *MutexBench) Event(ctx context.Context) (numReads, numWrites, numOthers, numIgnoredErros uint64, err error) {
+	if _, loaded := m.done.LoadOrStore(sysbench.ThreadID(ctx), struct{}{}); loaded {
+		return 0, 0, 0, 0, sysbench.ErrNoMoreEvents
+	}
+
+	for i := 0; i < m.opts.MutexLocks; i++ {
+		var n int
+		for j := 0; j < m.opts.MutexLoops; j++ {
+			n += j
+		}
+
+		slot := &m.slots[rand.Intn(len(m.slots))]
+		slot.mu.Lock()
+		slot.value += n
+		slot.mu.Unlock()
+	}
+	return 0, 0, 0, 0, nil
+}
+
+// Summary prints nothing, since sysbench mutex reports only the general statistics.
+func (m *MutexBench) Summary(result *sysbench.Result) {
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/samitani/go-sysbench"
+)
+
+func TestMutexBenchOneEventPerThread(t *testing.T) {
+	runnerOpts := &sysbench.RunnerOpts{Threads: 4, Time: 60, Histogram: "off", Percentile: 95}
+	bench := newMutexBench(&MutexOpts{MutexNum: 16, MutexLocks: 100, MutexLoops: 10})
+
+	if err := sysbench.NewRunner(runnerOpts, bench).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	var count int
+	bench.done.Range(func(_, _ any) bool {
+		count++
+		return true
+	})
+	if count != runnerOpts.Threads {
+		t.Errorf("Expected %d threads to run an event, got %d", runnerOpts.Threads, count)
+	}
+
+	if _, _, _, _, err := bench.Event(context.Background()); err != sysbench.ErrNoMoreEvents {
+		t.Errorf("Expected ErrNoMoreEvents for the second event of a thread, got %v", err)
+	}
+}
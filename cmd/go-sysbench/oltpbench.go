@@ -1,14 +1,21 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"database/sql"
 	"fmt"
 	"golang.org/x/exp/slices"
+	"io"
 	"math/rand"
+	"os"
 	"strconv"
 	"strings"
+	"time"
 
+	"cloud.google.com/go/spanner"
 	"github.com/go-sql-driver/mysql"
 	"github.com/googleapis/go-sql-spanner"
 	"github.com/lib/pq"
@@ -21,6 +28,7 @@ import (
 const (
 	NameOLTPReadOnly  = "oltp_read_only"
 	NameOLTPReadWrite = "oltp_read_write"
+	NameOLTPSecondary = "oltp_secondary_index"
 
 	DBDriverMySQL   = "mysql"
 	DBDriverPgSQL   = "pgsql"
@@ -59,75 +67,145 @@ const (
 	// Number of DELETE/INSERT combinations per transaction
 	numDeleteInserts = 1
 
-	OptSSLOn  = "on"
-	OptSSLOff = "off"
+	OptSSLOn         = "on"
+	OptSSLOff        = "off"
+	OptSSLVerifyCA   = "verify-ca"
+	OptSSLVerifyFull = "verify-full"
+
+	// name of tls.Config registered to MySQL driver
+	mysqlTLSConfigName = "go-sysbench"
 
 	OptIgnoreErrsAll = "all"
 
 	OptDBPreparedStmtAuto    = "auto"
 	OptDBPreparedStmtDisable = "disable"
+	OptDBPreparedStmtServer  = "server"
+
+	OptAutoCreateOn  = "on"
+	OptAutoCreateOff = "off"
+
+	OptOn  = "on"
+	OptOff = "off"
+
+	OptPrepareMethodInsert = "insert"
+	OptPrepareMethodLoad   = "load"
+
+	OptSpannerDialectGoogleSQL  = "googlesql"
+	OptSpannerDialectPostgreSQL = "postgresql"
+
+	OptSpannerReadModeTransaction = "transaction"
+	OptSpannerReadModeSingleUse   = "single-use"
+
+	defaultTablePrefix = "sbtest"
 
 	rwModeReadOnly  = "ro"
 	rwModeReadWrite = "rw"
+	// read only, through the secondary index on k
+	rwModeSecondary = "si"
 )
 
 var stmtsMySQL map[string]string = map[string]string{
-	"stmtPointSelects":    "SELECT c FROM sbtest%d WHERE id=?",
-	"stmtSimpleRanges":    "SELECT c FROM sbtest%d WHERE id BETWEEN ? AND ?",
-	"stmtSumRanges":       "SELECT SUM(k) FROM sbtest%d WHERE id BETWEEN ? AND ?",
-	"stmtOrderRanges":     "SELECT c FROM sbtest%d WHERE id BETWEEN ? AND ? ORDER BY c",
-	"stmtDistinctRanges":  "SELECT DISTINCT c FROM sbtest%d WHERE id BETWEEN ? AND ? ORDER BY c",
-	"stmtIndexUpdates":    "UPDATE sbtest%d SET k=k+1 WHERE id=?",
-	"stmtNonIndexUpdates": "UPDATE sbtest%d SET c=? WHERE id=?",
-	"stmtDeletes":         "DELETE FROM sbtest%d WHERE id=?",
-	"stmtInserts":         "INSERT INTO sbtest%d (id, k, c, pad) VALUES (?, ?, ?, ?)",
+	"stmtPointSelects":    "SELECT c FROM %s WHERE id=?",
+	"stmtSimpleRanges":    "SELECT c FROM %s WHERE id BETWEEN ? AND ?",
+	"stmtSumRanges":       "SELECT SUM(k) FROM %s WHERE id BETWEEN ? AND ?",
+	"stmtOrderRanges":     "SELECT c FROM %s WHERE id BETWEEN ? AND ? ORDER BY c",
+	"stmtDistinctRanges":  "SELECT DISTINCT c FROM %s WHERE id BETWEEN ? AND ? ORDER BY c",
+	"stmtIndexUpdates":    "UPDATE %s SET k=k+1 WHERE id=?",
+	"stmtNonIndexUpdates": "UPDATE %s SET c=? WHERE id=?",
+	"stmtDeletes":         "DELETE FROM %s WHERE id=?",
+	"stmtInserts":         "INSERT INTO %s (id, k, c, pad) VALUES (?, ?, ?, ?)",
 }
 
 var stmtsPgSQL map[string]string = map[string]string{
-	"stmtPointSelects":    "SELECT c FROM sbtest%d WHERE id=$1",
-	"stmtSimpleRanges":    "SELECT c FROM sbtest%d WHERE id BETWEEN $1 AND $2",
-	"stmtSumRanges":       "SELECT SUM(k) FROM sbtest%d WHERE id BETWEEN $1 AND $2",
-	"stmtOrderRanges":     "SELECT c FROM sbtest%d WHERE id BETWEEN $1 AND $2 ORDER BY c",
-	"stmtDistinctRanges":  "SELECT DISTINCT c FROM sbtest%d WHERE id BETWEEN $1 AND $2 ORDER BY c",
-	"stmtIndexUpdates":    "UPDATE sbtest%d SET k=k+1 WHERE id=$1",
-	"stmtNonIndexUpdates": "UPDATE sbtest%d SET c=$1 WHERE id=$2",
-	"stmtDeletes":         "DELETE FROM sbtest%d WHERE id=$1",
-	"stmtInserts":         "INSERT INTO sbtest%d (id, k, c, pad) VALUES ($1, $2, $3, $4)",
+	"stmtPointSelects":    "SELECT c FROM %s WHERE id=$1",
+	"stmtSimpleRanges":    "SELECT c FROM %s WHERE id BETWEEN $1 AND $2",
+	"stmtSumRanges":       "SELECT SUM(k) FROM %s WHERE id BETWEEN $1 AND $2",
+	"stmtOrderRanges":     "SELECT c FROM %s WHERE id BETWEEN $1 AND $2 ORDER BY c",
+	"stmtDistinctRanges":  "SELECT DISTINCT c FROM %s WHERE id BETWEEN $1 AND $2 ORDER BY c",
+	"stmtIndexUpdates":    "UPDATE %s SET k=k+1 WHERE id=$1",
+	"stmtNonIndexUpdates": "UPDATE %s SET c=$1 WHERE id=$2",
+	"stmtDeletes":         "DELETE FROM %s WHERE id=$1",
+	"stmtInserts":         "INSERT INTO %s (id, k, c, pad) VALUES ($1, $2, $3, $4)",
+}
+
+// statements which --read-hosts serve
+var readStmtNames = []string{"stmtPointSelects", "stmtSimpleRanges", "stmtSumRanges", "stmtOrderRanges", "stmtDistinctRanges"}
+
+// Spanner PostgreSQL dialect reports gRPC status codes instead of SQLSTATE.
+// They are translated so that --pgsql-ignore-errors can be applied to them.
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+var spannerPgSQLStates map[codes.Code]string = map[codes.Code]string{
+	codes.Aborted:       "40001", // serialization_failure
+	codes.AlreadyExists: "23505", // unique_violation
 }
 
 type (
 	MySQLOpts struct {
-		MySQLHost       string `long:"mysql-host" description:"MySQL server host" default:"localhost"`
+		MySQLHost       string `long:"mysql-host" description:"comma separated list of MySQL server hosts. host:port overrides --mysql-port" default:"localhost"`
 		MySQLPort       int    `long:"mysql-port" description:"MySQL server port" default:"3306"`
 		MySQLUser       string `long:"mysql-user" description:"MySQL user" default:"sbtest"`
 		MySQLPassword   string `long:"mysql-password" env:"MYSQL_PWD" description:"MySQL password" default:""`
 		MySQLDB         string `long:"mysql-db" description:"MySQL database name" default:"sbtest"`
-		MySQLSSL        string `long:"mysql-ssl" choice:"on" choice:"off" description:"use SSL connections" default:"off"` //nolint:staticcheck
+		MySQLSocket     string `long:"mysql-socket" description:"MySQL socket file to connect instead of --mysql-host and --mysql-port. --mysql-ssl=verify-full verifies the server certificate against the single --mysql-host"`
+		MySQLSSL        string `long:"mysql-ssl" choice:"on" choice:"off" choice:"verify-ca" choice:"verify-full" description:"use SSL connections. on does not verify the server certificate. verify-ca verifies it, verify-full also verifies the host name" default:"off"` //nolint:staticcheck
+		MySQLSSLCA      string `long:"mysql-ssl-ca" description:"CA certificate file to verify the server certificate"`
+		MySQLSSLCert    string `long:"mysql-ssl-cert" description:"client certificate file"`
+		MySQLSSLKey     string `long:"mysql-ssl-key" description:"client private key file. required with --mysql-ssl-cert"`
 		MySQLIgnoreErrs string `long:"mysql-ignore-errors" description:"list of errors to ignore, or \"all\"" default:"1213,1020,1205"`
+		MySQLEngine     string `long:"mysql-storage-engine" description:"storage engine" default:"innodb"`
 	}
 
 	PgSQLOpts struct {
-		PgSQLHost       string `long:"pgsql-host" description:"PostgreSQL server host" default:"localhost"`
-		PgSQLPort       int    `long:"pgsql-port" description:"PostgreSQL server port" default:"5432"`
-		PgSQLUser       string `long:"pgsql-user" description:"PostgreSQL user" default:"sbtest"`
-		PgSQLPassword   string `long:"pgsql-password" env:"PGPASSWORD" description:"PostgreSQL password" default:""`
-		PgSQLDB         string `long:"pgsql-db" description:"PostgreSQL database name" default:"sbtest"`
-		PgSQLSSL        string `long:"pgsql-ssl" choice:"on" choice:"off" description:"use SSL connections" default:"off"` //nolint:staticcheck
-		PgSQLIgnoreErrs string `long:"pgsql-ignore-errors" description:"list of errors to ignore, or \"all\"" default:"40P01,23505,40001"`
+		PgSQLHost        string `long:"pgsql-host" description:"comma separated list of PostgreSQL server hosts. host:port overrides --pgsql-port" default:"localhost"`
+		PgSQLPort        int    `long:"pgsql-port" description:"PostgreSQL server port" default:"5432"`
+		PgSQLUser        string `long:"pgsql-user" description:"PostgreSQL user" default:"sbtest"`
+		PgSQLPassword    string `long:"pgsql-password" env:"PGPASSWORD" description:"PostgreSQL password" default:""`
+		PgSQLDB          string `long:"pgsql-db" description:"PostgreSQL database name" default:"sbtest"`
+		PgSQLSocket      string `long:"pgsql-socket" description:"PostgreSQL socket directory to connect instead of --pgsql-host"`
+		PgSQLSSL         string `long:"pgsql-ssl" choice:"on" choice:"off" choice:"verify-ca" choice:"verify-full" description:"use SSL connections. verify-ca verifies the server certificate, verify-full also verifies the host name" default:"off"` //nolint:staticcheck
+		PgSQLSSLRootCert string `long:"pgsql-sslrootcert" description:"CA certificate file to verify the server certificate"`
+		PgSQLSSLCert     string `long:"pgsql-sslcert" description:"client certificate file"`
+		PgSQLSSLKey      string `long:"pgsql-sslkey" description:"client private key file. required with --pgsql-sslcert"`
+		PgSQLIgnoreErrs  string `long:"pgsql-ignore-errors" description:"list of errors to ignore, or \"all\"" default:"40P01,23505,40001"`
 	}
 
 	SpannerOpts struct {
-		SpannerProjectId  string `long:"spanner-project" description:"Spanner Google Cloud project name"`
-		SpannerInstanceId string `long:"spanner-instance" description:"Spanner instance id"`
-		SpannerDB         string `long:"spanner-db" description:"Spanner database name" default:"sbtest"`
+		SpannerProjectId      string `long:"spanner-project" description:"Spanner Google Cloud project name"`
+		SpannerInstanceId     string `long:"spanner-instance" description:"Spanner instance id"`
+		SpannerDB             string `long:"spanner-db" description:"Spanner database name" default:"sbtest"`
+		SpannerDialect        string `long:"spanner-dialect" choice:"googlesql" choice:"postgresql" description:"Spanner database dialect" default:"googlesql"` //nolint:staticcheck
+		SpannerEmulator       string `long:"spanner-emulator-host" env:"SPANNER_EMULATOR_HOST" description:"Spanner emulator host:port"`
+		SpannerPrepareThreads int    `long:"spanner-prepare-threads" description:"number of threads to write mutations in prepare" default:"8"`
+		SpannerStaleness      string `long:"spanner-staleness" description:"timestamp bound of reads in oltp_read_only: strong, exact:DURATION or max:DURATION" default:"strong"`
+		SpannerReadMode       string `long:"spanner-read-mode" choice:"transaction" choice:"single-use" description:"run reads of oltp_read_only in a read-only transaction or as single-use reads" default:"transaction"` //nolint:staticcheck
+		SpannerPriority       string `long:"spanner-priority" choice:"unspecified" choice:"low" choice:"medium" choice:"high" description:"request priority" default:"unspecified"`                                        //nolint:staticcheck
+		SpannerRequestTag     string `long:"spanner-request-tag" description:"request tag attached to each statement and transaction"`
+		SpannerAutoCreate     string `long:"spanner-auto-create" choice:"on" choice:"off" description:"create instance and database on the emulator if not exist" default:"off"` //nolint:staticcheck
 	}
 
 	CommonOpts struct {
-		Tables         int    `long:"tables" description:"number of tables" default:"1"`
-		TableSize      int    `long:"table_size" description:"number of rows per table" default:"10000"`
-		TableSizeP     int    `long:"table-size" description:"alias of --table_size"`
-		DBDriver       string `long:"db-driver" choice:"mysql" choice:"pgsql" choice:"spanner" description:"specifies database driver to use" default:"mysql"` //nolint:staticcheck
-		DBPreparedStmt string `long:"db-ps-mode" choice:"auto" choice:"disable" description:"prepared statements usage mode" default:"auto"`                   //nolint:staticcheck
+		Tables           int    `long:"tables" description:"number of tables" default:"1"`
+		TableSize        int    `long:"table_size" description:"number of rows per table" default:"10000"`
+		TableSizeP       int    `long:"table-size" description:"alias of --table_size"`
+		DBDriver         string `long:"db-driver" choice:"mysql" choice:"pgsql" choice:"spanner" description:"specifies database driver to use" default:"mysql"`                                                                         //nolint:staticcheck
+		DBPreparedStmt   string `long:"db-ps-mode" choice:"auto" choice:"disable" choice:"server" description:"prepared statements usage mode. server prepares statements once on a connection dedicated to each thread" default:"auto"` //nolint:staticcheck
+		TablePrefix      string `long:"table-prefix" description:"prefix of table names" default:"sbtest"`
+		AutoInc          string `long:"auto-inc" choice:"on" choice:"off" description:"use AUTO_INCREMENT column as Primary Key (for MySQL), or its alternatives in other DBMS" default:"on"` //nolint:staticcheck
+		Secondary        string `long:"secondary" choice:"on" choice:"off" description:"use a secondary index in place of the PRIMARY KEY" default:"off"`                                     //nolint:staticcheck
+		CreateSecondary  string `long:"create-secondary" choice:"on" choice:"off" description:"create a secondary index in addition to the PRIMARY KEY" default:"on"`                         //nolint:staticcheck
+		CreateTableOpts  string `long:"create-table-options" description:"extra CREATE TABLE options"`
+		PartitionBy      string `long:"partition-by" choice:"none" choice:"hash" choice:"range" description:"partition tables by id" default:"none"` //nolint:staticcheck
+		Partitions       int    `long:"partitions" description:"number of partitions per table" default:"1"`
+		PartitionTargets string `long:"partition-targets" description:"comma separated list of range partitions (0-origin) which events access. all partitions if empty"`
+		TableCheck       string `long:"table-check" choice:"on" choice:"off" description:"verify that tables exist and match --table_size before run" default:"on"` //nolint:staticcheck
+		ReadHosts        string `long:"read-hosts" description:"comma separated list of replica hosts which SELECT statements are sent to outside the transaction on --mysql-host or --pgsql-host"`
+		LagHosts         string `long:"lag-hosts" description:"comma separated list of replica hosts to measure replication lag from the first --mysql-host or --pgsql-host with a heartbeat table"`
+		LagInterval      int    `long:"lag-interval" description:"interval in milliseconds to measure replication lag" default:"1000"`
+		ServerMetrics    string `long:"server-metrics" description:"comma separated list of server counters to report deltas of during run. SHOW GLOBAL STATUS variables for MySQL, pg_stat_database columns for PostgreSQL"`
+		LockingReads     string `long:"locking-reads" choice:"off" choice:"for-update" choice:"for-share" description:"lock rows read by point selects in oltp_read_write transactions" default:"off"`                                                            //nolint:staticcheck
+		SkipTrx          string `long:"skip-trx" choice:"on" choice:"off" description:"run statements in autocommit without BEGIN/COMMIT" default:"off"`                                                                                                          //nolint:staticcheck
+		Isolation        string `long:"isolation" choice:"default" choice:"read-uncommitted" choice:"read-committed" choice:"repeatable-read" choice:"serializable" description:"transaction isolation level. default uses the server default" default:"default"` //nolint:staticcheck
+		PrepareMethod    string `long:"prepare-method" choice:"insert" choice:"load" description:"how to load records in prepare. load uses LOAD DATA LOCAL INFILE for MySQL and COPY for PostgreSQL" default:"insert"`                                           //nolint:staticcheck
 	}
 
 	BenchmarkOpts struct {
@@ -135,6 +213,14 @@ type (
 		MySQLOpts   `group:"MySQL" description:"MySQL options"`
 		PgSQLOpts   `group:"PostgreSQL" description:"PostgreSQL options"`
 		SpannerOpts `group:"Spanner" description:"Google Cloud Spanner options"`
+		CPUOpts     `group:"CPU" description:"cpu benchmark options"`
+		MemoryOpts  `group:"Memory" description:"memory benchmark options"`
+		FileIOOpts  `group:"File I/O" description:"fileio benchmark options"`
+		ThreadsOpts `group:"Threads" description:"threads benchmark options"`
+		MutexOpts   `group:"Mutex" description:"mutex benchmark options"`
+		TPCCOpts    `group:"TPC-C" description:"tpcc benchmark options"`
+		KVOpts      `group:"KV" description:"kv benchmark options"`
+		HotRowsOpts `group:"Hot rows" description:"hot_rows benchmark options"`
 	}
 
 	OLTPBench struct {
@@ -144,8 +230,21 @@ type (
 		ignoreErrSlice []string
 		db             *sql.DB
 		staticStmts    map[int]map[string]string
-		preparedStmts  map[int]map[string]*sql.Stmt // tableNum -> stmtName -> preparedStmt
-		eventFuncRef   func(context.Context) (uint64, uint64, uint64, error)
+		hosts          []*dbHost
+		readHosts      []*dbHost
+		lagHosts       []*dbHost
+		lagInterval    lagStats
+
+		metricNames  []string
+		metricsStart map[string]float64 // snapshot before events
+		metricsLast  map[string]float64 // snapshot at the last intermediate report
+		metricsEnd   map[string]float64 // snapshot after events
+		eventFuncRef func(context.Context) (uint64, uint64, uint64, error)
+		idRanges     []idRange
+
+		spannerStaleness spanner.TimestampBound
+		spannerTxOpts    spanner.TransactionOptions
+		spannerExecOpts  *spannerdriver.ExecOptions
 	}
 )
 
@@ -158,13 +257,31 @@ func benchmarkFactory(testname string, opt *BenchmarkOpts) (sysbench.Benchmark,
 		return newOLTPBench(opt, rwModeReadOnly), nil
 	} else if testname == NameOLTPReadWrite {
 		return newOLTPBench(opt, rwModeReadWrite), nil
+	} else if testname == NameOLTPSecondary {
+		return newOLTPBench(opt, rwModeSecondary), nil
+	} else if testname == NameCPU {
+		return newCPUBench(&opt.CPUOpts), nil
+	} else if testname == NameMemory {
+		return newMemoryBench(&opt.MemoryOpts), nil
+	} else if testname == NameFileIO {
+		return newFileIOBench(&opt.FileIOOpts), nil
+	} else if testname == NameThreads {
+		return newThreadsBench(&opt.ThreadsOpts), nil
+	} else if testname == NameMutex {
+		return newMutexBench(&opt.MutexOpts), nil
+	} else if testname == NameTPCC {
+		return newTPCCBench(opt), nil
+	} else if testname == NameKV {
+		return newKVBench(opt), nil
+	} else if testname == NameHotRows {
+		return newHotRowsBench(opt), nil
 	}
 	return nil, fmt.Errorf("Unknown benchmark: %s", testname)
 
 }
 
 func benchmarkNames() []string {
-	return []string{NameOLTPReadOnly, NameOLTPReadWrite}
+	return []string{NameOLTPReadOnly, NameOLTPReadWrite, NameOLTPSecondary, NameCPU, NameMemory, NameFileIO, NameThreads, NameMutex, NameTPCC, NameKV, NameHotRows}
 }
 
 func newOLTPBench(option *BenchmarkOpts, mode string) *OLTPBench {
@@ -174,6 +291,8 @@ func newOLTPBench(option *BenchmarkOpts, mode string) *OLTPBench {
 		ignoreErrors = strings.Split(option.MySQLIgnoreErrs, ",")
 	} else if option.DBDriver == DBDriverPgSQL {
 		ignoreErrors = strings.Split(option.PgSQLIgnoreErrs, ",")
+	} else if option.DBDriver == DBDriverSpanner && option.SpannerDialect == OptSpannerDialectPostgreSQL {
+		ignoreErrors = strings.Split(option.PgSQLIgnoreErrs, ",")
 	}
 
 	return &OLTPBench{opts: option, ignoreErrSlice: ignoreErrors, rwMode: mode}
@@ -183,15 +302,48 @@ func (o *OLTPBench) Init(ctx context.Context) error {
 	var drvName string
 	var dsn string
 
-	if o.opts.DBDriver == DBDriverMySQL {
-		drvName = "mysql"
-		dsn = o.dsnMySQL()
-	} else if o.opts.DBDriver == DBDriverPgSQL {
-		drvName = "postgres"
-		dsn = o.dsnPgSQL()
+	if o.opts.SkipTrx == OptOn && o.opts.Isolation != OptIsolationDefault {
+		return fmt.Errorf("--isolation can not be used with --skip-trx")
+	}
+
+	if o.opts.DBDriver == DBDriverMySQL || o.opts.DBDriver == DBDriverPgSQL {
+		return o.initHosts()
 	} else if o.opts.DBDriver == DBDriverSpanner {
+		if o.opts.ReadHosts != "" || o.opts.LagHosts != "" || o.opts.ServerMetrics != "" {
+			return fmt.Errorf("--read-hosts, --lag-hosts and --server-metrics are not supported by %s driver", DBDriverSpanner)
+		}
+		// read-write transactions of Spanner are always serializable
+		if o.opts.Isolation != OptIsolationDefault {
+			return fmt.Errorf("--isolation is not supported by %s driver", DBDriverSpanner)
+		}
+		// go-sql-spanner parses statements on client side
+		if o.opts.DBPreparedStmt == OptDBPreparedStmtServer {
+			return fmt.Errorf("--db-ps-mode=%s is not supported by %s driver", OptDBPreparedStmtServer, DBDriverSpanner)
+		}
+
 		drvName = "spanner"
 		dsn = o.dsnSpanner()
+
+		if o.opts.SpannerEmulator != "" {
+			// the Spanner client libraries connect to the emulator when this is set
+			err := os.Setenv("SPANNER_EMULATOR_HOST", o.opts.SpannerEmulator)
+			if err != nil {
+				return err
+			}
+
+			if o.opts.SpannerAutoCreate == OptAutoCreateOn {
+				err = o.createSpannerInstance(ctx)
+				if err != nil {
+					return err
+				}
+				err = o.createSpannerDatabase(ctx)
+				if err != nil {
+					return err
+				}
+			}
+		} else if o.opts.SpannerAutoCreate == OptAutoCreateOn {
+			return fmt.Errorf("--spanner-auto-create requires --spanner-emulator-host")
+		}
 	} else {
 		panic("Unexpected driver")
 	}
@@ -207,59 +359,289 @@ func (o *OLTPBench) Init(ctx context.Context) error {
 	}
 
 	o.db = db
+	o.hosts = []*dbHost{newDBHost(hostAddr{}, db)}
+
+	return nil
+}
+
+// initHosts connects to each of --mysql-host or --pgsql-host, --read-hosts and --lag-hosts.
+// The first host is also used for prepare and table verification.
+func (o *OLTPBench) initHosts() error {
+	var hosts, socket string
+	var port int
+
+	if o.opts.DBDriver == DBDriverMySQL {
+		hosts, port, socket = o.opts.MySQLHost, o.opts.MySQLPort, o.opts.MySQLSocket
+		if (o.opts.MySQLSSLCert == "") != (o.opts.MySQLSSLKey == "") {
+			return fmt.Errorf("--mysql-ssl-cert and --mysql-ssl-key have to be given together")
+		}
+	} else {
+		hosts, port, socket = o.opts.PgSQLHost, o.opts.PgSQLPort, o.opts.PgSQLSocket
+		if (o.opts.PgSQLSSLCert == "") != (o.opts.PgSQLSSLKey == "") {
+			return fmt.Errorf("--pgsql-sslcert and --pgsql-sslkey have to be given together")
+		}
+	}
+
+	addrs, err := parseHosts(hosts, port)
+	if err != nil {
+		return err
+	}
+	if socket != "" && (len(addrs) > 1 || o.opts.ReadHosts != "" || o.opts.LagHosts != "") {
+		return fmt.Errorf("unix domain socket can not be used with multiple hosts, --read-hosts or --lag-hosts")
+	}
+
+	o.hosts, err = o.openHosts(addrs)
+	if err != nil {
+		return err
+	}
+	o.db = o.hosts[0].db
+
+	if o.opts.ReadHosts != "" {
+		addrs, err = parseHosts(o.opts.ReadHosts, port)
+		if err != nil {
+			return err
+		}
+		o.readHosts, err = o.openHosts(addrs)
+		if err != nil {
+			return err
+		}
+	}
+
+	if o.opts.LagHosts != "" {
+		addrs, err = parseHosts(o.opts.LagHosts, port)
+		if err != nil {
+			return err
+		}
+		o.lagHosts, err = o.openHosts(addrs)
+		if err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
+// openHosts opens a connection pool to each host.
+// Pools opened before an error are returned as well so that Done() closes them.
+func (o *OLTPBench) openHosts(addrs []hostAddr) ([]*dbHost, error) {
+	var hosts []*dbHost
+
+	drvName := "mysql"
+	if o.opts.DBDriver == DBDriverPgSQL {
+		drvName = "postgres"
+	}
+
+	for _, addr := range addrs {
+		var dsn string
+		var err error
+		if o.opts.DBDriver == DBDriverMySQL {
+			dsn, err = o.dsnMySQL(addr)
+			if err != nil {
+				return hosts, err
+			}
+		} else {
+			dsn = o.dsnPgSQL(addr)
+		}
+
+		db, err := sql.Open(drvName, dsn)
+		if err != nil {
+			return hosts, err
+		}
+		hosts = append(hosts, newDBHost(addr, db))
+
+		err = db.Ping()
+		if err != nil {
+			return hosts, fmt.Errorf("failed to connect to %s: %w", addr, err)
+		}
+	}
+	return hosts, nil
+}
+
 func (o *OLTPBench) PreEvent(ctx context.Context) error {
 	var stmtTemplates map[string]string
 
+	err := o.validatePartitionOpts()
+	if err != nil {
+		return err
+	}
+	err = o.validateLockingReads()
+	if err != nil {
+		return err
+	}
+	o.idRanges, err = o.parsePartitionTargets()
+	if err != nil {
+		return err
+	}
+
+	if o.opts.TableCheck == OptOn {
+		err = o.verifyTables(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = o.printIsolation(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(o.lagHosts) > 0 {
+		err = o.createHeartbeat(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
 	if o.opts.DBDriver == DBDriverMySQL {
 		stmtTemplates = stmtsMySQL
 	} else if o.opts.DBDriver == DBDriverPgSQL {
 		stmtTemplates = stmtsPgSQL
+	} else if o.opts.DBDriver == DBDriverSpanner && o.opts.SpannerDialect == OptSpannerDialectPostgreSQL {
+		stmtTemplates = stmtsPgSQL
 	} else if o.opts.DBDriver == DBDriverSpanner {
 		stmtTemplates = stmtsMySQL
 	} else {
 		panic("Unexpected driver")
 	}
 
-	var err error
-	if o.opts.DBPreparedStmt == OptDBPreparedStmtDisable {
-		o.staticStmts = make(map[int]map[string]string)
-		for tableNum := 1; tableNum <= o.opts.Tables; tableNum++ {
-			o.staticStmts[tableNum] = make(map[string]string)
-			for stmtName, stmtString := range stmtTemplates {
-				o.staticStmts[tableNum][stmtName] = fmt.Sprintf(stmtString, tableNum)
-			}
+	if o.opts.LockingReads != OptLockingReadsOff {
+		lockingTemplates := make(map[string]string)
+		for stmtName, stmtString := range stmtTemplates {
+			lockingTemplates[stmtName] = stmtString
+		}
+		lockingTemplates["stmtPointSelects"] = o.lockingRead(stmtTemplates["stmtPointSelects"], o.opts.LockingReads)
+		stmtTemplates = lockingTemplates
+	}
+
+	readStmts := readStmtNames
+	if o.rwMode == rwModeSecondary {
+		if o.opts.DBDriver == DBDriverPgSQL || (o.opts.DBDriver == DBDriverSpanner && o.opts.SpannerDialect == OptSpannerDialectPostgreSQL) {
+			stmtTemplates = stmtsSecondaryPgSQL
+		} else {
+			stmtTemplates = stmtsSecondaryMySQL
 		}
-		o.eventFuncRef = o.eventFuncStaticStmt()
+		readStmts = nil
+		for stmtName := range stmtTemplates {
+			readStmts = append(readStmts, stmtName)
+		}
+	}
+
+	if o.opts.DBDriver == DBDriverSpanner {
+		err = o.setupSpanner()
+		if err != nil {
+			return err
+		}
+	}
+
+	err = o.prepareStmts(ctx, o.formatStmts(stmtTemplates), readStmts)
+	if err != nil {
+		return err
+	}
+
+	if o.rwMode == rwModeSecondary {
+		o.eventFuncRef = o.eventFuncSecondaryIndex()
 	} else {
-		o.preparedStmts = make(map[int]map[string]*sql.Stmt)
-		for tableNum := 1; tableNum <= o.opts.Tables; tableNum++ {
-			o.preparedStmts[tableNum] = make(map[string]*sql.Stmt)
-			for stmtName, stmtString := range stmtTemplates {
-				o.preparedStmts[tableNum][stmtName], err = o.db.PrepareContext(ctx, fmt.Sprintf(stmtString, tableNum))
-				if err != nil {
-					return err
-				}
-			}
+		o.eventFuncRef = o.eventFuncOLTP()
+	}
+
+	if o.opts.ServerMetrics != "" {
+		err = o.startServerMetrics(ctx)
+		if err != nil {
+			return err
 		}
-		o.eventFuncRef = o.eventFuncPreparedStmt()
 	}
 	return nil
 }
 
 func (o *OLTPBench) Prepare(ctx context.Context) error {
-	err := o.createTable()
+	err := o.createTable(ctx)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// Check scans all the tables and verifies records created by prepare.
+func (o *OLTPBench) Check(ctx context.Context) error {
+	var failed int
+
+	for tableNum := 1; tableNum <= o.opts.Tables; tableNum++ {
+		table := o.tableName(tableNum)
+		fmt.Printf("Checking table '%s'...\n", table)
+
+		problems, err := o.checkTable(ctx, tableNum)
+		if err != nil {
+			return fmt.Errorf("failed to check table '%s': %w", table, err)
+		}
+
+		for _, p := range problems {
+			fmt.Printf("    %s\n", p)
+		}
+		if len(problems) > 0 {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d tables are inconsistent with --tables=%d --table_size=%d", failed, o.opts.Tables, o.opts.Tables, o.opts.TableSize)
+	}
+	fmt.Println("All tables are consistent")
+
+	return nil
+}
+
+// Cleanup drops the tables created by prepare.
+func (o *OLTPBench) Cleanup(ctx context.Context) error {
+	for tableNum := 1; tableNum <= o.opts.Tables; tableNum++ {
+		table := o.tableName(tableNum)
+		fmt.Printf("Dropping table '%s'...\n", table)
+
+		if o.opts.DBDriver == DBDriverSpanner {
+			err := o.dropTableSpanner(ctx, tableNum)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		_, err := o.db.ExecContext(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s", table))
+		if err != nil {
+			return err
+		}
+	}
+
+	if o.opts.DBDriver != DBDriverSpanner {
+		_, err := o.db.ExecContext(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s", o.heartbeatTable()))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Report prints statistics per host, replication lag and server metrics after the runner's statistics.
+func (o *OLTPBench) Report(result *sysbench.Result) {
+	o.reportHosts(result)
+	o.reportLag()
+	o.reportServerMetrics(result)
+}
+
 func (o *OLTPBench) Event(ctx context.Context) (numReads, numWrites, numOthers, numIgnoredErros uint64, err error) {
-	numReads, numWrites, numOthers, err = o.eventFuncRef(ctx)
+	return o.runEvent(ctx, o.eventFuncRef)
+}
+
+// runEvent runs the event function on the host of the thread, records its latency and ignores errors in --*-ignore-errors.
+func (o *OLTPBench) runEvent(ctx context.Context, eventFunc func(context.Context) (uint64, uint64, uint64, error)) (numReads, numWrites, numOthers, numIgnoredErros uint64, err error) {
+	h := o.hostFor(ctx)
+	eventBegin := time.Now()
+	defer func() {
+		// with --read-hosts, event functions record reads and writes separately
+		if err == nil && numIgnoredErros == 0 && len(o.readHosts) == 0 {
+			h.record(time.Since(eventBegin))
+		}
+	}()
+
+	numReads, numWrites, numOthers, err = eventFunc(ctx)
 
 	if err != nil {
 		if o.opts.DBDriver == DBDriverMySQL {
@@ -283,8 +665,15 @@ func (o *OLTPBench) Event(ctx context.Context) (numReads, numWrites, numOthers,
 				return numReads, numWrites, numOthers, 1, nil
 			}
 
-			// convert spanner specific context error to general error
 			s, ok := status.FromError(err)
+			if ok && o.opts.SpannerDialect == OptSpannerDialectPostgreSQL {
+				sqlState, found := spannerPgSQLStates[s.Code()]
+				if found && (o.opts.PgSQLIgnoreErrs == OptIgnoreErrsAll || slices.Contains(o.ignoreErrSlice, sqlState)) {
+					return numReads, numWrites, numOthers, 1, nil
+				}
+			}
+
+			// convert spanner specific context error to general error
 			if ok {
 				if s.Code() == codes.DeadlineExceeded {
 					err = context.DeadlineExceeded
@@ -299,322 +688,388 @@ func (o *OLTPBench) Event(ctx context.Context) (numReads, numWrites, numOthers,
 }
 
 func (o *OLTPBench) Done() error {
-	o.db.Close()
+	if o.metricsStart != nil {
+		var err error
+		o.metricsEnd, err = o.sampleServerMetrics(context.Background())
+		if err != nil {
+			fmt.Printf("failed to sample server metrics: %s\n", err)
+		}
+	}
+
+	for _, hosts := range [][]*dbHost{o.hosts, o.readHosts, o.lagHosts} {
+		for _, h := range hosts {
+			h.closeThreadConns()
+			h.db.Close()
+		}
+	}
 	return nil
 }
 
-func (o *OLTPBench) dsnMySQL() string {
+func (o *OLTPBench) dsnMySQL(addr hostAddr) (string, error) {
 	var tlsParam string
-	if o.opts.MySQLSSL == OptSSLOn {
+	if o.opts.MySQLSSL == OptSSLOff {
+		tlsParam = "false"
+	} else if o.opts.MySQLSSL == OptSSLOn && o.opts.MySQLSSLCA == "" && o.opts.MySQLSSLCert == "" {
 		tlsParam = "skip-verify"
 	} else {
-		tlsParam = "false"
+		tlsConfig, err := o.mysqlTLSConfig(addr)
+		if err != nil {
+			return "", err
+		}
+		err = mysql.RegisterTLSConfig(mysqlTLSConfigName, tlsConfig)
+		if err != nil {
+			return "", err
+		}
+		tlsParam = mysqlTLSConfigName
+	}
+
+	var netAddr string
+	if o.opts.MySQLSocket != "" {
+		netAddr = fmt.Sprintf("unix(%s)", o.opts.MySQLSocket)
+	} else {
+		netAddr = fmt.Sprintf("tcp(%s)", addr)
+	}
+
+	return fmt.Sprintf("%s:%s@%s/%s?tls=%s&interpolateParams=%s", o.opts.MySQLUser, o.opts.MySQLPassword, netAddr, o.opts.MySQLDB, tlsParam, "true"), nil
+}
+
+// mysqlTLSConfig builds tls.Config from --mysql-ssl* options for the host.
+func (o *OLTPBench) mysqlTLSConfig(addr hostAddr) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+	if o.opts.MySQLSocket != "" {
+		// a socket has no host name, so the certificate is verified against the host parsed from --mysql-host,
+		// which is a single host without port. otherwise the driver takes the server name from the address.
+		tlsConfig.ServerName = addr.host
+	}
+
+	if o.opts.MySQLSSLCA != "" {
+		pem, err := os.ReadFile(o.opts.MySQLSSLCA)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("failed to load CA certificate: %s", o.opts.MySQLSSLCA)
+		}
+	}
+
+	if o.opts.MySQLSSLCert != "" || o.opts.MySQLSSLKey != "" {
+		cert, err := tls.LoadX509KeyPair(o.opts.MySQLSSLCert, o.opts.MySQLSSLKey)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if o.opts.MySQLSSL == OptSSLOn {
+		// on encrypts the connection without verifying the server, even with a CA or a client certificate
+		tlsConfig.InsecureSkipVerify = true
+	} else if o.opts.MySQLSSL == OptSSLVerifyCA {
+		// verify the certificate chain without the host name
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+			opts := x509.VerifyOptions{Roots: tlsConfig.RootCAs, Intermediates: x509.NewCertPool()}
+			for _, cert := range cs.PeerCertificates[1:] {
+				opts.Intermediates.AddCert(cert)
+			}
+			_, err := cs.PeerCertificates[0].Verify(opts)
+			return err
+		}
 	}
 
-	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?tls=%s&interpolateParams=%s", o.opts.MySQLUser, o.opts.MySQLPassword, o.opts.MySQLHost, o.opts.MySQLPort, o.opts.MySQLDB, tlsParam, "true")
+	return tlsConfig, nil
 }
 
-func (o *OLTPBench) dsnPgSQL() string {
+func (o *OLTPBench) dsnPgSQL(addr hostAddr) string {
 	var sslParam string
 	if o.opts.PgSQLSSL == OptSSLOn {
 		sslParam = "require"
-	} else {
+	} else if o.opts.PgSQLSSL == OptSSLOff {
 		sslParam = "disable"
+	} else {
+		sslParam = o.opts.PgSQLSSL
 	}
-	return fmt.Sprintf("user=%s password=%s host=%s port=%d dbname=%s sslmode=%s", o.opts.PgSQLUser, o.opts.PgSQLPassword, o.opts.PgSQLHost, o.opts.PgSQLPort, o.opts.PgSQLDB, sslParam)
-}
 
-func (o *OLTPBench) eventFuncStaticStmt() func(context.Context) (uint64, uint64, uint64, error) {
-	var txOpt *sql.TxOptions
-	if o.opts.DBDriver == DBDriverSpanner && o.rwMode == rwModeReadOnly {
-		txOpt = &sql.TxOptions{ReadOnly: true}
-	} else {
-		txOpt = &sql.TxOptions{}
+	host := addr.host
+	if o.opts.PgSQLSocket != "" {
+		// lib/pq connects to the unix domain socket if host begins with a slash
+		host = o.opts.PgSQLSocket
+	}
+
+	dsn := fmt.Sprintf("user=%s password=%s host=%s port=%d dbname=%s sslmode=%s", o.opts.PgSQLUser, o.opts.PgSQLPassword, host, addr.port, o.opts.PgSQLDB, sslParam)
+
+	if o.opts.PgSQLSSLRootCert != "" {
+		dsn += fmt.Sprintf(" sslrootcert=%s", o.opts.PgSQLSSLRootCert)
+	}
+	if o.opts.PgSQLSSLCert != "" {
+		dsn += fmt.Sprintf(" sslcert=%s", o.opts.PgSQLSSLCert)
 	}
+	if o.opts.PgSQLSSLKey != "" {
+		dsn += fmt.Sprintf(" sslkey=%s", o.opts.PgSQLSSLKey)
+	}
+	return dsn
+}
+
+// eventFuncOLTP runs the statements of oltp_read_only and oltp_read_write through the executor of --db-ps-mode.
+func (o *OLTPBench) eventFuncOLTP() func(context.Context) (uint64, uint64, uint64, error) {
+	txOpt := o.txOptions(o.opts.DBDriver == DBDriverSpanner && o.rwMode == rwModeReadOnly)
+	useTx := o.useTx()
 
 	return func(ctx context.Context) (numReads, numWrites, numOthers uint64, err error) {
 		var tableNum = o.getRandTableNum()
 
-		tx, err := o.db.BeginTx(ctx, txOpt)
+		var inTx bool
+		var writeBegin time.Time
+
+		h := o.hostFor(ctx)
+		r := o.readHostFor(ctx)
 
+		w, err := o.executorFor(ctx, h)
 		if err != nil {
 			return numReads, numWrites, numOthers, err
 		}
-		numOthers += 1
+		defer w.release()
 
-		for i := 0; i < numPointSelects; i++ {
-			rows, err := tx.QueryContext(ctx, o.staticStmts[tableNum]["stmtPointSelects"], sbRand(1, o.opts.TableSize))
+		// with --read-hosts, SELECT statements run on the replica before the transaction on the primary.
+		// with --skip-trx, statements run in autocommit.
+		reader := w
+		if r != nil {
+			reader, err = o.executorFor(ctx, r)
 			if err != nil {
-				_ = tx.Rollback()
 				return numReads, numWrites, numOthers, err
 			}
-			for rows.Next() {
-			}
-			rows.Close()
-			numReads += 1
-		}
-
-		for i := 0; i < numSimpleRanges; i++ {
-			begin := sbRand(1, o.opts.TableSize)
-			rows, err := tx.QueryContext(ctx, o.staticStmts[tableNum]["stmtSimpleRanges"], begin, begin+rangeSize-1)
-			if err != nil {
-				_ = tx.Rollback()
-				return numReads, numWrites, numOthers, err
-			}
-			for rows.Next() {
-			}
-			rows.Close()
-			numReads += 1
-		}
-		for i := 0; i < numSumRanges; i++ {
-			begin := sbRand(1, o.opts.TableSize)
-			rows, err := tx.QueryContext(ctx, o.staticStmts[tableNum]["stmtSumRanges"], begin, begin+rangeSize-1)
+			defer reader.release()
+		} else if useTx {
+			err = w.begin(ctx, txOpt)
 			if err != nil {
-				_ = tx.Rollback()
 				return numReads, numWrites, numOthers, err
 			}
-			for rows.Next() {
-			}
-			rows.Close()
-			numReads += 1
+			inTx = true
+			numOthers += 1
 		}
 
-		for i := 0; i < numOrderRanges; i++ {
-			begin := sbRand(1, o.opts.TableSize)
-			rows, err := tx.QueryContext(ctx, o.staticStmts[tableNum]["stmtOrderRanges"], begin, begin+rangeSize-1)
+		query := func(stmtName string, args ...any) error {
+			rows, err := reader.query(ctx, tableNum, stmtName, args...)
 			if err != nil {
-				_ = tx.Rollback()
-				return numReads, numWrites, numOthers, err
+				w.rollback()
+				return err
 			}
 			for rows.Next() {
 			}
 			rows.Close()
 			numReads += 1
+			return nil
 		}
 
-		for i := 0; i < numDistinctRanges; i++ {
-			begin := sbRand(1, o.opts.TableSize)
-			rows, err := tx.QueryContext(ctx, o.staticStmts[tableNum]["stmtDistinctRanges"], begin, begin+rangeSize-1)
+		exec := func(stmtName string, args ...any) error {
+			changed, err := execCounted(ctx, w, tableNum, stmtName, args...)
 			if err != nil {
-				_ = tx.Rollback()
-				return numReads, numWrites, numOthers, err
-			}
-			for rows.Next() {
-			}
-			rows.Close()
-			numReads += 1
-		}
-
-		if o.rwMode == rwModeReadWrite {
-			for i := 0; i < numIndexUpdates; i++ {
-				_, err := tx.ExecContext(ctx, o.staticStmts[tableNum]["stmtIndexUpdates"], sbRand(1, o.opts.TableSize))
-				if err != nil {
-					_ = tx.Rollback()
-					return numReads, numWrites, numOthers, err
-				}
-				numWrites += 1
-			}
-			for i := 0; i < numNonIndexUpdates; i++ {
-				_, err := tx.ExecContext(ctx, o.staticStmts[tableNum]["stmtNonIndexUpdates"], getCValue(), sbRand(1, o.opts.TableSize))
-				if err != nil {
-					_ = tx.Rollback()
-					return numReads, numWrites, numOthers, err
-				}
-				numWrites += 1
+				w.rollback()
+				return err
 			}
-			for i := 0; i < numDeleteInserts; i++ {
-				id := sbRand(1, o.opts.TableSize)
-
-				_, err := tx.ExecContext(ctx, o.staticStmts[tableNum]["stmtDeletes"], id)
-				if err != nil {
-					_ = tx.Rollback()
-					return numReads, numWrites, numOthers, err
-				}
-				numWrites += 1
-
-				_, err = tx.ExecContext(ctx, o.staticStmts[tableNum]["stmtInserts"], id, sbRand(1, o.opts.TableSize), getCValue(), getPadValue())
-				if err != nil {
-					_ = tx.Rollback()
-					return numReads, numWrites, numOthers, err
-				}
+			if changed {
 				numWrites += 1
+			} else {
+				numOthers += 1
 			}
-
-		}
-
-		err = tx.Commit()
-		if err != nil {
-			return numReads, numWrites, numOthers, err
+			return nil
 		}
-		numOthers += 1
 
-		return numReads, numWrites, numOthers, nil
-	}
-}
-
-func (o *OLTPBench) eventFuncPreparedStmt() func(context.Context) (uint64, uint64, uint64, error) {
-	var txOpt *sql.TxOptions
-	if o.opts.DBDriver == DBDriverSpanner && o.rwMode == rwModeReadOnly {
-		txOpt = &sql.TxOptions{ReadOnly: true}
-	} else {
-		txOpt = &sql.TxOptions{}
-	}
-
-	return func(ctx context.Context) (numReads, numWrites, numOthers uint64, err error) {
-		var tableNum = o.getRandTableNum()
-
-		tx, err := o.db.BeginTx(ctx, txOpt)
-
-		if err != nil {
-			return numReads, numWrites, numOthers, err
-		}
-		numOthers += 1
+		readBegin := time.Now()
 
 		for i := 0; i < numPointSelects; i++ {
-			rows, err := tx.Stmt(o.preparedStmts[tableNum]["stmtPointSelects"]).QueryContext(ctx, sbRand(1, o.opts.TableSize))
-			if err != nil {
-				_ = tx.Rollback()
+			if err = query("stmtPointSelects", o.getRandId()); err != nil {
 				return numReads, numWrites, numOthers, err
 			}
-			for rows.Next() {
-			}
-			rows.Close()
-			numReads += 1
 		}
 
 		for i := 0; i < numSimpleRanges; i++ {
-			begin := sbRand(1, o.opts.TableSize)
-			rows, err := tx.Stmt(o.preparedStmts[tableNum]["stmtSimpleRanges"]).QueryContext(ctx, begin, begin+rangeSize-1)
-			if err != nil {
-				_ = tx.Rollback()
+			begin := o.getRandId()
+			if err = query("stmtSimpleRanges", begin, begin+rangeSize-1); err != nil {
 				return numReads, numWrites, numOthers, err
 			}
-			for rows.Next() {
-			}
-			rows.Close()
-			numReads += 1
 		}
 
 		for i := 0; i < numSumRanges; i++ {
-			begin := sbRand(1, o.opts.TableSize)
-			rows, err := tx.Stmt(o.preparedStmts[tableNum]["stmtSumRanges"]).QueryContext(ctx, begin, begin+rangeSize-1)
-			if err != nil {
-				_ = tx.Rollback()
+			begin := o.getRandId()
+			if err = query("stmtSumRanges", begin, begin+rangeSize-1); err != nil {
 				return numReads, numWrites, numOthers, err
 			}
-			for rows.Next() {
-			}
-			rows.Close()
-			numReads += 1
 		}
 
 		for i := 0; i < numOrderRanges; i++ {
-			begin := sbRand(1, o.opts.TableSize)
-			rows, err := tx.Stmt(o.preparedStmts[tableNum]["stmtOrderRanges"]).QueryContext(ctx, begin, begin+rangeSize-1)
-			if err != nil {
-				_ = tx.Rollback()
+			begin := o.getRandId()
+			if err = query("stmtOrderRanges", begin, begin+rangeSize-1); err != nil {
 				return numReads, numWrites, numOthers, err
 			}
-			for rows.Next() {
-			}
-			rows.Close()
-			numReads += 1
 		}
 
 		for i := 0; i < numDistinctRanges; i++ {
-			begin := sbRand(1, o.opts.TableSize)
-			rows, err := tx.Stmt(o.preparedStmts[tableNum]["stmtDistinctRanges"]).QueryContext(ctx, begin, begin+rangeSize-1)
-			if err != nil {
-				_ = tx.Rollback()
+			begin := o.getRandId()
+			if err = query("stmtDistinctRanges", begin, begin+rangeSize-1); err != nil {
 				return numReads, numWrites, numOthers, err
 			}
-			for rows.Next() {
-			}
-			rows.Close()
-			numReads += 1
+		}
+
+		if r != nil {
+			r.record(time.Since(readBegin))
 		}
 
 		if o.rwMode == rwModeReadWrite {
-			for i := 0; i < numIndexUpdates; i++ {
-				res, err := tx.Stmt(o.preparedStmts[tableNum]["stmtIndexUpdates"]).ExecContext(ctx, sbRand(1, o.opts.TableSize))
-				if err != nil {
-					_ = tx.Rollback()
-					return numReads, numWrites, numOthers, err
-				}
-				rows, err := res.RowsAffected()
-				if err != nil {
-					return numReads, numWrites, numOthers, err
-				}
-				if rows == 0 {
+			if r != nil {
+				writeBegin = time.Now()
+				if useTx {
+					err = w.begin(ctx, txOpt)
+					if err != nil {
+						return numReads, numWrites, numOthers, err
+					}
+					inTx = true
 					numOthers += 1
-				} else {
-					numWrites += 1
 				}
 			}
-			for i := 0; i < numNonIndexUpdates; i++ {
-				res, err := tx.Stmt(o.preparedStmts[tableNum]["stmtNonIndexUpdates"]).ExecContext(ctx, getCValue(), sbRand(1, o.opts.TableSize))
-				if err != nil {
-					_ = tx.Rollback()
+
+			for i := 0; i < numIndexUpdates; i++ {
+				if err = exec("stmtIndexUpdates", o.getRandId()); err != nil {
 					return numReads, numWrites, numOthers, err
 				}
-				rows, err := res.RowsAffected()
-				if err != nil {
+			}
+			for i := 0; i < numNonIndexUpdates; i++ {
+				if err = exec("stmtNonIndexUpdates", getCValue(), o.getRandId()); err != nil {
 					return numReads, numWrites, numOthers, err
 				}
-				if rows == 0 {
-					numOthers += 1
-				} else {
-					numWrites += 1
-				}
 			}
 			for i := 0; i < numDeleteInserts; i++ {
-				id := sbRand(1, o.opts.TableSize)
+				id := o.getRandId()
 
-				res, err := tx.Stmt(o.preparedStmts[tableNum]["stmtDeletes"]).ExecContext(ctx, id)
-				if err != nil {
-					_ = tx.Rollback()
+				if err = exec("stmtDeletes", id); err != nil {
 					return numReads, numWrites, numOthers, err
 				}
-				rows, err := res.RowsAffected()
-				if err != nil {
+				if err = exec("stmtInserts", id, sbRand(1, o.opts.TableSize), getCValue(), getPadValue()); err != nil {
 					return numReads, numWrites, numOthers, err
 				}
-				if rows == 0 {
-					numOthers += 1
-				} else {
-					numWrites += 1
-				}
+			}
+		}
 
-				res, err = tx.Stmt(o.preparedStmts[tableNum]["stmtInserts"]).ExecContext(ctx, id, sbRand(1, o.opts.TableSize), getCValue(), getPadValue())
-				if err != nil {
-					_ = tx.Rollback()
-					return numReads, numWrites, numOthers, err
-				}
-				rows, err = res.RowsAffected()
-				if err != nil {
-					return numReads, numWrites, numOthers, err
-				}
-				if rows == 0 {
-					numOthers += 1
-				} else {
-					numWrites += 1
-				}
+		if inTx {
+			err = w.commit(ctx)
+			if err != nil {
+				return numReads, numWrites, numOthers, err
 			}
+			numOthers += 1
+		}
+		if r != nil && o.rwMode == rwModeReadWrite {
+			h.record(time.Since(writeBegin))
+		}
+
+		return numReads, numWrites, numOthers, nil
+	}
+}
+
+func (o *OLTPBench) dsnSpanner() string {
+	if o.opts.SpannerEmulator != "" {
+		return fmt.Sprintf("%s/%s;usePlainText=true", o.opts.SpannerEmulator, o.spannerDatabasePath())
+	}
+	return o.spannerDatabasePath()
+}
+
+// bindArgs returns query arguments in the form the driver can bind.
+// go-sql-spanner does not recognize $n placeholders, so PostgreSQL dialect
+// arguments are passed as named parameters p1, p2, ...
+// Spanner request options are passed as an additional argument.
+func (o *OLTPBench) bindArgs(args ...any) []any {
+	if o.opts.DBDriver != DBDriverSpanner {
+		return args
+	}
+
+	if o.opts.SpannerDialect == OptSpannerDialectPostgreSQL {
+		for i, arg := range args {
+			args[i] = sql.Named(fmt.Sprintf("p%d", i+1), arg)
+		}
+	}
+	if o.spannerExecOpts != nil {
+		args = append(args, *o.spannerExecOpts)
+	}
+	return args
+}
 
+func (o *OLTPBench) beginTx(ctx context.Context, db *sql.DB, txOpt *sql.TxOptions) (*sql.Tx, error) {
+	if o.opts.DBDriver == DBDriverSpanner {
+		if txOpt.ReadOnly {
+			return spannerdriver.BeginReadOnlyTransaction(ctx, db, spannerdriver.ReadOnlyTransactionOptions{TimestampBound: o.spannerStaleness})
 		}
+		return spannerdriver.BeginReadWriteTransaction(ctx, db, spannerdriver.ReadWriteTransactionOptions{TransactionOptions: o.spannerTxOpts})
+	}
+	return db.BeginTx(ctx, txOpt)
+}
 
-		err = tx.Commit()
+// verifyTables checks that each table exists and has ids up to --table_size.
+// It looks up only the max id, which is cheap with the primary key.
+func (o *OLTPBench) verifyTables(ctx context.Context) error {
+	for tableNum := 1; tableNum <= o.opts.Tables; tableNum++ {
+		table := o.tableName(tableNum)
+
+		var maxId sql.NullInt64
+		err := o.db.QueryRowContext(ctx, fmt.Sprintf("SELECT MAX(id) FROM %s", table)).Scan(&maxId)
 		if err != nil {
-			return numReads, numWrites, numOthers, err
+			return fmt.Errorf("table '%s' is not accessible. run prepare with the same --tables and --table-prefix first: %w", table, err)
+		}
+		if !maxId.Valid {
+			return fmt.Errorf("table '%s' is empty. run prepare first", table)
 		}
-		numOthers += 1
+		if maxId.Int64 != int64(o.opts.TableSize) {
+			return fmt.Errorf("max id of table '%s' is %d, which does not match --table_size=%d. prepare and run should use the same --table_size", table, maxId.Int64, o.opts.TableSize)
+		}
+	}
+	return nil
+}
 
-		return numReads, numWrites, numOthers, nil
+// checkTable runs a full scan of the table and returns inconsistencies found.
+func (o *OLTPBench) checkTable(ctx context.Context, tableNum int) ([]string, error) {
+	var problems []string
+	var count, distinct int64
+	var minId, maxId sql.NullInt64
+
+	table := o.tableName(tableNum)
+
+	err := o.db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*), COUNT(DISTINCT id), MIN(id), MAX(id) FROM %s", table)).Scan(&count, &distinct, &minId, &maxId)
+	if err != nil {
+		return nil, err
+	}
+
+	if count != int64(o.opts.TableSize) {
+		problems = append(problems, fmt.Sprintf("number of rows is %d, expected %d", count, o.opts.TableSize))
+	}
+	if distinct != count {
+		problems = append(problems, fmt.Sprintf("%d rows have duplicate ids", count-distinct))
+	}
+	if minId.Int64 != 1 || maxId.Int64 != int64(o.opts.TableSize) {
+		problems = append(problems, fmt.Sprintf("ids range from %d to %d, expected 1 to %d", minId.Int64, maxId.Int64, o.opts.TableSize))
+	}
+
+	var malformed int64
+	err = o.db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE LENGTH(c) <> %d OR LENGTH(pad) <> %d", table, len(getCValue()), len(getPadValue()))).Scan(&malformed)
+	if err != nil {
+		return nil, err
 	}
+	if malformed > 0 {
+		problems = append(problems, fmt.Sprintf("%d rows have malformed c or pad values", malformed))
+	}
+
+	return problems, nil
 }
 
-func (o *OLTPBench) dsnSpanner() string {
-	return fmt.Sprintf("projects/%s/instances/%s/databases/%s", o.opts.SpannerProjectId, o.opts.SpannerInstanceId, o.opts.SpannerDB)
+func (o *OLTPBench) tableName(tableNum int) string {
+	return fmt.Sprintf("%s%d", o.opts.TablePrefix, tableNum)
+}
+
+// indexName returns the name of an index on the table.
+// Index names have to be unique in a schema on PostgreSQL and Spanner,
+// so they are prefixed by --table-prefix unless it is the default.
+func (o *OLTPBench) indexName(name string, tableNum int) string {
+	if o.opts.TablePrefix == defaultTablePrefix {
+		return fmt.Sprintf("%s_%d", name, tableNum)
+	}
+	return fmt.Sprintf("%s_%s_%d", o.opts.TablePrefix, name, tableNum)
 }
 
 func (o *OLTPBench) getRandTableNum() int {
@@ -649,70 +1104,119 @@ func sbRandStr(format string) string {
 	return string(buf)
 }
 
-func (o *OLTPBench) createTable() error {
+func (o *OLTPBench) createTable(ctx context.Context) error {
+	if o.opts.PrepareMethod == OptPrepareMethodLoad && o.opts.DBDriver == DBDriverSpanner {
+		return fmt.Errorf("--prepare-method=%s is not supported by %s driver", OptPrepareMethodLoad, DBDriverSpanner)
+	}
+	if o.opts.Secondary == OptOn && o.opts.DBDriver == DBDriverSpanner {
+		return fmt.Errorf("--secondary is not supported by %s driver", DBDriverSpanner)
+	}
+	err := o.validatePartitionOpts()
+	if err != nil {
+		return err
+	}
+
 	var idDef string
+	var idIndexDef string
+	var engineDef string
 
 	if o.opts.DBDriver == DBDriverPgSQL {
-		idDef = "INT NOT NULL"
-	} else {
-		idDef = "INT NOT NULL AUTO_INCREMENT"
+		if o.opts.AutoInc == OptOn {
+			idDef = "SERIAL"
+		} else {
+			idDef = "INT NOT NULL"
+		}
+	} else if o.opts.DBDriver == DBDriverMySQL {
+		if o.opts.AutoInc == OptOn {
+			idDef = "INT NOT NULL AUTO_INCREMENT"
+		} else {
+			idDef = "INT NOT NULL"
+		}
+		engineDef = fmt.Sprintf("/*! ENGINE = %s */", o.opts.MySQLEngine)
 	}
 
-	idIndexDef := "PRIMARY KEY"
+	if o.opts.Secondary == OptOff {
+		idIndexDef = ",\n PRIMARY KEY (id)"
+	} else if o.opts.DBDriver == DBDriverMySQL {
+		idIndexDef = ",\n KEY xid (id)"
+	}
 
 	for tableNum := 1; tableNum <= o.opts.Tables; tableNum++ {
-		fmt.Printf("Creating table 'sbtest%d'...\n", tableNum)
+		table := o.tableName(tableNum)
+
+		fmt.Printf("Creating table '%s'...\n", table)
 		var query string
 
-		if o.opts.DBDriver == DBDriverSpanner {
-			query = fmt.Sprintf(`CREATE TABLE sbtest%d (
+		partitionDef, partitionStmts := o.partitionDDL(tableNum)
+
+		if o.opts.DBDriver == DBDriverSpanner && o.opts.SpannerDialect == OptSpannerDialectPostgreSQL {
+			query = fmt.Sprintf(`CREATE TABLE %s (
+				id BIGINT NOT NULL,
+				k BIGINT NOT NULL DEFAULT 0,
+				c VARCHAR(120) NOT NULL DEFAULT '',
+				pad VARCHAR(60) NOT NULL DEFAULT '',
+				PRIMARY KEY (id)
+			) %s`, table, o.opts.CreateTableOpts)
+		} else if o.opts.DBDriver == DBDriverSpanner {
+			query = fmt.Sprintf(`CREATE TABLE %s (
 				id INT64 NOT NULL,
 				k INT64 NOT NULL DEFAULT(0),
 				c STRING(120) NOT NULL DEFAULT(''),
 				pad STRING(60) NOT NULL DEFAULT(''),
-			) PRIMARY KEY (id)`, tableNum)
+			) PRIMARY KEY (id) %s`, table, o.opts.CreateTableOpts)
 		} else {
-			query = fmt.Sprintf(`CREATE TABLE sbtest%d(
+			query = fmt.Sprintf(`CREATE TABLE %s(
                                                    id %s,
                                                    k INTEGER DEFAULT '0' NOT NULL,
                                                    c CHAR(120) DEFAULT '' NOT NULL,
-                                                   pad CHAR(60) DEFAULT '' NOT NULL,
-                                                   %s (id)
-                                     )`, tableNum, idDef, idIndexDef)
+                                                   pad CHAR(60) DEFAULT '' NOT NULL%s
+                                     ) %s %s %s`, table, idDef, idIndexDef, engineDef, o.opts.CreateTableOpts, partitionDef)
 		}
 
-		_, err := o.db.Exec(query)
+		_, err = o.db.Exec(query)
 		if err != nil {
 			return err
 		}
 
-		fmt.Printf("Inserting %d records into 'sbtest%d'\n", o.opts.TableSize, tableNum)
-		insertValues := []string{}
-		for i := 1; i <= o.opts.TableSize; i++ {
-			insertValues = append(insertValues, fmt.Sprintf(`(%d, %d, '%s', '%s') `, i, sbRand(1, o.opts.TableSize), getCValue(), getPadValue()))
-
-			// Spanner max query size is 1M
-			// https://cloud.google.com/spanner/quotas#query-limits
-			if i%500 == 0 {
-				query = fmt.Sprintf("INSERT INTO sbtest%d (id, k, c, pad) VALUES", tableNum) + strings.Join(insertValues, ",")
-				_, err = o.db.Exec(query)
-				if err != nil {
-					return err
-				}
-				insertValues = []string{}
+		for _, stmt := range partitionStmts {
+			_, err = o.db.Exec(stmt)
+			if err != nil {
+				return err
 			}
 		}
-		if len(insertValues) > 0 {
-			query = fmt.Sprintf("INSERT INTO sbtest%d (id, k, c, pad) VALUES", tableNum) + strings.Join(insertValues, ",")
-			_, err = o.db.Exec(query)
+
+		// PostgreSQL does not accept index definitions in CREATE TABLE
+		if o.opts.Secondary == OptOn && o.opts.DBDriver == DBDriverPgSQL {
+			_, err = o.db.Exec(fmt.Sprintf("CREATE INDEX %s ON %s(id)", o.indexName("xid", tableNum), table))
 			if err != nil {
 				return err
 			}
 		}
 
-		fmt.Printf("Creating a secondary index on 'sbtest%d'...\n", tableNum)
-		query = fmt.Sprintf("CREATE INDEX k_%d ON sbtest%d(k)", tableNum, tableNum)
-		_, err = o.db.Exec(query)
+		fmt.Printf("Inserting %d records into '%s'\n", o.opts.TableSize, table)
+		if o.opts.DBDriver == DBDriverSpanner {
+			err = o.insertRowsSpanner(ctx, tableNum)
+		} else if o.opts.PrepareMethod == OptPrepareMethodLoad && o.opts.DBDriver == DBDriverMySQL {
+			err = o.loadRowsMySQL(tableNum)
+		} else if o.opts.PrepareMethod == OptPrepareMethodLoad && o.opts.DBDriver == DBDriverPgSQL {
+			err = o.copyRowsPgSQL(tableNum)
+		} else {
+			err = o.insertRows(tableNum)
+		}
+		if err != nil {
+			return err
+		}
+
+		if o.opts.CreateSecondary == OptOff {
+			continue
+		}
+
+		fmt.Printf("Creating a secondary index on '%s'...\n", table)
+		if o.opts.DBDriver == DBDriverSpanner {
+			err = o.createIndexSpanner(ctx, tableNum)
+		} else {
+			_, err = o.db.Exec(fmt.Sprintf("CREATE INDEX %s ON %s(k)", o.indexName("k", tableNum), table))
+		}
 		if err != nil {
 			return err
 		}
@@ -720,3 +1224,93 @@ func (o *OLTPBench) createTable() error {
 
 	return nil
 }
+
+func (o *OLTPBench) insertRows(tableNum int) error {
+	insertValues := []string{}
+	for i := 1; i <= o.opts.TableSize; i++ {
+		insertValues = append(insertValues, fmt.Sprintf(`(%d, %d, '%s', '%s') `, i, sbRand(1, o.opts.TableSize), getCValue(), getPadValue()))
+
+		if i%500 == 0 {
+			query := fmt.Sprintf("INSERT INTO %s (id, k, c, pad) VALUES", o.tableName(tableNum)) + strings.Join(insertValues, ",")
+			_, err := o.db.Exec(query)
+			if err != nil {
+				return err
+			}
+			insertValues = []string{}
+		}
+	}
+	if len(insertValues) > 0 {
+		query := fmt.Sprintf("INSERT INTO %s (id, k, c, pad) VALUES", o.tableName(tableNum)) + strings.Join(insertValues, ",")
+		_, err := o.db.Exec(query)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadRowsMySQL streams generated rows to LOAD DATA LOCAL INFILE.
+// The server must be started with local_infile=ON.
+func (o *OLTPBench) loadRowsMySQL(tableNum int) error {
+	pr, pw := io.Pipe()
+
+	go func() {
+		w := bufio.NewWriter(pw)
+		for i := 1; i <= o.opts.TableSize; i++ {
+			_, err := fmt.Fprintf(w, "%d,%d,%s,%s\n", i, sbRand(1, o.opts.TableSize), getCValue(), getPadValue())
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.CloseWithError(w.Flush())
+	}()
+
+	name := o.tableName(tableNum)
+	mysql.RegisterReaderHandler(name, func() io.Reader { return pr })
+	defer mysql.DeregisterReaderHandler(name)
+
+	_, err := o.db.Exec(fmt.Sprintf("LOAD DATA LOCAL INFILE 'Reader::%s' INTO TABLE %s FIELDS TERMINATED BY ',' (id, k, c, pad)", name, name))
+
+	// unblock the writer if the server stopped reading
+	pr.CloseWithError(io.ErrClosedPipe)
+
+	return err
+}
+
+// copyRowsPgSQL streams generated rows with COPY FROM STDIN.
+func (o *OLTPBench) copyRowsPgSQL(tableNum int) error {
+	tx, err := o.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(pq.CopyIn(o.tableName(tableNum), "id", "k", "c", "pad"))
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	for i := 1; i <= o.opts.TableSize; i++ {
+		_, err = stmt.Exec(i, sbRand(1, o.opts.TableSize), getCValue(), getPadValue())
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	// flush buffered rows
+	_, err = stmt.Exec()
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	err = stmt.Close()
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
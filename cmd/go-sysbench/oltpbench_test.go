@@ -0,0 +1,142 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDSNMySQL(t *testing.T) {
+	opts := &BenchmarkOpts{}
+	opts.MySQLUser = "sbtest"
+	opts.MySQLPassword = "secret"
+	opts.MySQLHost = "db1"
+	opts.MySQLPort = 3307
+	opts.MySQLDB = "sbtest"
+	opts.MySQLSSL = OptSSLOff
+
+	o := newOLTPBench(opts, rwModeReadWrite)
+
+	tests := []struct {
+		socket   string
+		ssl      string
+		expected string
+	}{
+		{"", OptSSLOff, "sbtest:secret@tcp(db1:3307)/sbtest?tls=false&interpolateParams=true"},
+		{"", OptSSLOn, "sbtest:secret@tcp(db1:3307)/sbtest?tls=skip-verify&interpolateParams=true"},
+		{"", OptSSLVerifyFull, "sbtest:secret@tcp(db1:3307)/sbtest?tls=go-sysbench&interpolateParams=true"},
+		{"/var/run/mysqld/mysqld.sock", OptSSLOff, "sbtest:secret@unix(/var/run/mysqld/mysqld.sock)/sbtest?tls=false&interpolateParams=true"},
+	}
+
+	for _, tt := range tests {
+		opts.MySQLSocket = tt.socket
+		opts.MySQLSSL = tt.ssl
+
+		dsn, err := o.dsnMySQL(hostAddr{host: opts.MySQLHost, port: opts.MySQLPort})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dsn != tt.expected {
+			t.Errorf("Expected %q, got %q", tt.expected, dsn)
+		}
+	}
+}
+
+func TestDSNPgSQL(t *testing.T) {
+	opts := &BenchmarkOpts{}
+	opts.PgSQLUser = "sbtest"
+	opts.PgSQLPassword = "secret"
+	opts.PgSQLHost = "db1"
+	opts.PgSQLPort = 5432
+	opts.PgSQLDB = "sbtest"
+	opts.PgSQLSSL = OptSSLVerifyCA
+	opts.PgSQLSSLRootCert = "/etc/ssl/ca.pem"
+	opts.PgSQLSocket = "/var/run/postgresql"
+
+	o := newOLTPBench(opts, rwModeReadWrite)
+
+	expected := "user=sbtest password=secret host=/var/run/postgresql port=5432 dbname=sbtest sslmode=verify-ca sslrootcert=/etc/ssl/ca.pem"
+	if dsn := o.dsnPgSQL(hostAddr{host: opts.PgSQLHost, port: opts.PgSQLPort}); dsn != expected {
+		t.Errorf("Expected %q, got %q", expected, dsn)
+	}
+}
+
+func TestInitHostsClientCert(t *testing.T) {
+	opts := &BenchmarkOpts{}
+	opts.MySQLHost = "db1"
+	opts.MySQLPort = 3306
+	opts.MySQLSSL = OptSSLOn
+	opts.PgSQLHost = "db1"
+	opts.PgSQLPort = 5432
+	opts.PgSQLSSL = OptSSLOn
+
+	o := newOLTPBench(opts, rwModeReadWrite)
+
+	opts.DBDriver = DBDriverMySQL
+	opts.MySQLSSLKey = "/etc/ssl/client-key.pem"
+	if err := o.initHosts(); err == nil {
+		t.Errorf("Expected --mysql-ssl-key without --mysql-ssl-cert to be invalid")
+	}
+
+	opts.DBDriver = DBDriverPgSQL
+	opts.PgSQLSSLCert = "/etc/ssl/client-cert.pem"
+	if err := o.initHosts(); err == nil {
+		t.Errorf("Expected --pgsql-sslcert without --pgsql-sslkey to be invalid")
+	}
+}
+
+func TestMySQLTLSConfigServerName(t *testing.T) {
+	opts := &BenchmarkOpts{}
+	opts.DBDriver = DBDriverMySQL
+	opts.MySQLHost = "db1:3307"
+	opts.MySQLPort = 3306
+	opts.MySQLSSL = OptSSLVerifyFull
+
+	o := newOLTPBench(opts, rwModeReadWrite)
+
+	// the driver takes the server name from the TCP address
+	tlsConfig, err := o.mysqlTLSConfig(hostAddr{host: "db1", port: 3307})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig.ServerName != "" {
+		t.Errorf("Expected no server name over TCP, got %q", tlsConfig.ServerName)
+	}
+
+	// the host without port is verified over a socket
+	opts.MySQLSocket = "/var/run/mysqld/mysqld.sock"
+	tlsConfig, err = o.mysqlTLSConfig(hostAddr{host: "db1", port: 3307})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig.ServerName != "db1" {
+		t.Errorf("Expected server name db1, got %q", tlsConfig.ServerName)
+	}
+
+	// a host list has no single name to verify
+	opts.MySQLHost = "db1,db2"
+	if err = o.initHosts(); err == nil {
+		t.Errorf("Expected a host list with --mysql-socket to be invalid")
+	}
+}
+
+func TestParseHosts(t *testing.T) {
+	addrs, err := parseHosts("db1, db2:3307,[::1]:3308,::1,[::2]", 3306)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []hostAddr{{"db1", 3306}, {"db2", 3307}, {"::1", 3308}, {"::1", 3306}, {"::2", 3306}}
+	if !reflect.DeepEqual(addrs, expected) {
+		t.Errorf("Expected %v, got %v", expected, addrs)
+	}
+
+	if addrs[4].String() != "[::2]:3306" {
+		t.Errorf("Expected [::2]:3306, got %s", addrs[4])
+	}
+
+	for _, input := range []string{"", "db1,,db2", "db1:port", "[]"} {
+		if _, err := parseHosts(input, 3306); err == nil {
+			t.Errorf("Expected %q to be invalid", input)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	OptPartitionByNone  = "none"
+	OptPartitionByHash  = "hash"
+	OptPartitionByRange = "range"
+)
+
+// idRange is an inclusive range of ids
+type idRange struct {
+	first int
+	last  int
+}
+
+// partitionSize returns the number of ids in each range partition.
+func (o *OLTPBench) partitionSize() int {
+	return (o.opts.TableSize + o.opts.Partitions - 1) / o.opts.Partitions
+}
+
+// partitionRange returns the range of ids stored in the range partition.
+func (o *OLTPBench) partitionRange(partition int) idRange {
+	size := o.partitionSize()
+	return idRange{first: partition*size + 1, last: min((partition+1)*size, o.opts.TableSize)}
+}
+
+// validatePartitionOpts checks --partition-by, --partitions and --partition-targets.
+func (o *OLTPBench) validatePartitionOpts() error {
+	if o.opts.PartitionBy == OptPartitionByNone {
+		if o.opts.PartitionTargets != "" {
+			return fmt.Errorf("--partition-targets requires --partition-by=%s", OptPartitionByRange)
+		}
+		return nil
+	}
+
+	if o.opts.DBDriver == DBDriverSpanner {
+		return fmt.Errorf("--partition-by is not supported by %s driver", DBDriverSpanner)
+	}
+	if o.opts.Partitions < 1 || o.opts.Partitions > o.opts.TableSize {
+		return fmt.Errorf("--partitions should be between 1 and --table_size")
+	}
+	if o.opts.PartitionTargets != "" && o.opts.PartitionBy != OptPartitionByRange {
+		return fmt.Errorf("--partition-targets requires --partition-by=%s", OptPartitionByRange)
+	}
+	return nil
+}
+
+// parsePartitionTargets converts --partition-targets to the id ranges which events access.
+func (o *OLTPBench) parsePartitionTargets() ([]idRange, error) {
+	if o.opts.PartitionTargets == "" {
+		return nil, nil
+	}
+
+	var ranges []idRange
+	for _, s := range strings.Split(o.opts.PartitionTargets, ",") {
+		partition, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || partition < 0 || partition >= o.opts.Partitions {
+			return nil, fmt.Errorf("invalid --partition-targets: %s. partition numbers should be between 0 and %d", o.opts.PartitionTargets, o.opts.Partitions-1)
+		}
+		r := o.partitionRange(partition)
+		if r.first > r.last {
+			return nil, fmt.Errorf("partition %d in --partition-targets has no rows", partition)
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// partitionDDL returns the clause appended to CREATE TABLE, and statements to run after it.
+// PostgreSQL needs each partition to be created as a table.
+func (o *OLTPBench) partitionDDL(tableNum int) (string, []string) {
+	table := o.tableName(tableNum)
+
+	if o.opts.PartitionBy == OptPartitionByNone {
+		return "", nil
+	}
+
+	if o.opts.DBDriver == DBDriverPgSQL {
+		var stmts []string
+
+		for i := 0; i < o.opts.Partitions; i++ {
+			var bound string
+			if o.opts.PartitionBy == OptPartitionByHash {
+				bound = fmt.Sprintf("WITH (MODULUS %d, REMAINDER %d)", o.opts.Partitions, i)
+			} else if i == o.opts.Partitions-1 {
+				bound = fmt.Sprintf("FROM (%d) TO (MAXVALUE)", o.partitionRange(i).first)
+			} else {
+				bound = fmt.Sprintf("FROM (%d) TO (%d)", o.partitionRange(i).first, o.partitionRange(i).last+1)
+			}
+			stmts = append(stmts, fmt.Sprintf("CREATE TABLE %s_p%d PARTITION OF %s FOR VALUES %s", table, i, table, bound))
+		}
+
+		if o.opts.PartitionBy == OptPartitionByHash {
+			return "PARTITION BY HASH (id)", stmts
+		}
+		return "PARTITION BY RANGE (id)", stmts
+	}
+
+	if o.opts.PartitionBy == OptPartitionByHash {
+		return fmt.Sprintf("PARTITION BY HASH (id) PARTITIONS %d", o.opts.Partitions), nil
+	}
+
+	var defs []string
+	for i := 0; i < o.opts.Partitions; i++ {
+		if i == o.opts.Partitions-1 {
+			defs = append(defs, fmt.Sprintf("PARTITION p%d VALUES LESS THAN MAXVALUE", i))
+		} else {
+			defs = append(defs, fmt.Sprintf("PARTITION p%d VALUES LESS THAN (%d)", i, o.partitionRange(i).last+1))
+		}
+	}
+	return fmt.Sprintf("PARTITION BY RANGE (id) (%s)", strings.Join(defs, ", ")), nil
+}
+
+// getRandId returns a random id in the table, or in --partition-targets if specified.
+func (o *OLTPBench) getRandId() int {
+	if len(o.idRanges) == 0 {
+		return sbRand(1, o.opts.TableSize)
+	}
+
+	r := o.idRanges[sbRand(0, len(o.idRanges)-1)]
+	return sbRand(r.first, r.last)
+}
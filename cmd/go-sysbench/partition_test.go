@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+)
+
+func newPartitionedBench(driver, partitionBy string, partitions int, targets string) *OLTPBench {
+	opts := &BenchmarkOpts{}
+	opts.DBDriver = driver
+	opts.TablePrefix = "sbtest"
+	opts.TableSize = 1000
+	opts.PartitionBy = partitionBy
+	opts.Partitions = partitions
+	opts.PartitionTargets = targets
+
+	return newOLTPBench(opts, rwModeReadWrite)
+}
+
+func TestPartitionDDLMySQL(t *testing.T) {
+	def, stmts := newPartitionedBench(DBDriverMySQL, OptPartitionByRange, 3, "").partitionDDL(1)
+
+	expected := "PARTITION BY RANGE (id) (PARTITION p0 VALUES LESS THAN (335), PARTITION p1 VALUES LESS THAN (669), PARTITION p2 VALUES LESS THAN MAXVALUE)"
+	if def != expected {
+		t.Errorf("Expected %q, got %q", expected, def)
+	}
+	if len(stmts) != 0 {
+		t.Errorf("Expected no extra statements, got %v", stmts)
+	}
+
+	def, _ = newPartitionedBench(DBDriverMySQL, OptPartitionByHash, 4, "").partitionDDL(1)
+	if def != "PARTITION BY HASH (id) PARTITIONS 4" {
+		t.Errorf("Unexpected hash partition clause: %q", def)
+	}
+}
+
+func TestPartitionDDLPgSQL(t *testing.T) {
+	def, stmts := newPartitionedBench(DBDriverPgSQL, OptPartitionByRange, 2, "").partitionDDL(3)
+
+	if def != "PARTITION BY RANGE (id)" {
+		t.Errorf("Unexpected range partition clause: %q", def)
+	}
+	expected := []string{
+		"CREATE TABLE sbtest3_p0 PARTITION OF sbtest3 FOR VALUES FROM (1) TO (501)",
+		"CREATE TABLE sbtest3_p1 PARTITION OF sbtest3 FOR VALUES FROM (501) TO (MAXVALUE)",
+	}
+	if len(stmts) != len(expected) {
+		t.Fatalf("Expected %d statements, got %v", len(expected), stmts)
+	}
+	for i := range expected {
+		if stmts[i] != expected[i] {
+			t.Errorf("Expected %q, got %q", expected[i], stmts[i])
+		}
+	}
+
+	_, stmts = newPartitionedBench(DBDriverPgSQL, OptPartitionByHash, 2, "").partitionDDL(1)
+	if stmts[1] != "CREATE TABLE sbtest1_p1 PARTITION OF sbtest1 FOR VALUES WITH (MODULUS 2, REMAINDER 1)" {
+		t.Errorf("Unexpected hash partition statement: %q", stmts[1])
+	}
+}
+
+func TestGetRandIdWithPartitionTargets(t *testing.T) {
+	o := newPartitionedBench(DBDriverMySQL, OptPartitionByRange, 4, "1,3")
+
+	if err := o.validatePartitionOpts(); err != nil {
+		t.Fatal(err)
+	}
+	ranges, err := o.parsePartitionTargets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.idRanges = ranges
+
+	for i := 0; i < 10000; i++ {
+		id := o.getRandId()
+		if !(id >= 251 && id <= 500) && !(id >= 751 && id <= 1000) {
+			t.Fatalf("id %d is out of partition 1 and 3", id)
+		}
+	}
+}
+
+func TestPartitionTargetsValidation(t *testing.T) {
+	if err := newPartitionedBench(DBDriverMySQL, OptPartitionByHash, 4, "1").validatePartitionOpts(); err == nil {
+		t.Errorf("Expected --partition-targets with hash partitioning to be rejected")
+	}
+	if _, err := newPartitionedBench(DBDriverMySQL, OptPartitionByRange, 4, "4").parsePartitionTargets(); err == nil {
+		t.Errorf("Expected out of range partition to be rejected")
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// Number of SELECT by k queries per transaction
+	numIndexPointSelects = 10
+
+	// Number of SELECT by range of k queries per transaction
+	numIndexRanges = 1
+
+	// Number of SELECT by range of k queries which the index on k covers per transaction
+	numCoveringRanges = 1
+
+	// Number of JOIN on k queries per transaction
+	numJoinRanges = 1
+)
+
+// Statements of oltp_secondary_index. %[2]s is the table joined with the table of %s.
+var stmtsSecondaryMySQL map[string]string = map[string]string{
+	"stmtIndexPointSelects": "SELECT id, c FROM %s WHERE k=?",
+	"stmtIndexRanges":       "SELECT id, c FROM %s WHERE k BETWEEN ? AND ?",
+	"stmtCoveringRanges":    "SELECT COUNT(k) FROM %s WHERE k BETWEEN ? AND ?",
+	"stmtJoinRanges":        "SELECT a.id, b.id FROM %[1]s a JOIN %[2]s b ON a.k=b.k WHERE a.k BETWEEN ? AND ?",
+}
+
+var stmtsSecondaryPgSQL map[string]string = map[string]string{
+	"stmtIndexPointSelects": "SELECT id, c FROM %s WHERE k=$1",
+	"stmtIndexRanges":       "SELECT id, c FROM %s WHERE k BETWEEN $1 AND $2",
+	"stmtCoveringRanges":    "SELECT COUNT(k) FROM %s WHERE k BETWEEN $1 AND $2",
+	"stmtJoinRanges":        "SELECT a.id, b.id FROM %[1]s a JOIN %[2]s b ON a.k=b.k WHERE a.k BETWEEN $1 AND $2",
+}
+
+// formatStmt fills the table names of the statement template.
+func (o *OLTPBench) formatStmt(stmtString string, tableNum int) string {
+	if strings.Contains(stmtString, "%[2]s") {
+		return fmt.Sprintf(stmtString, o.tableName(tableNum), o.tableName(o.joinTableNum(tableNum)))
+	}
+	return fmt.Sprintf(stmtString, o.tableName(tableNum))
+}
+
+// joinTableNum returns the table joined with the table. Tables are joined with the next one, or itself with --tables=1.
+func (o *OLTPBench) joinTableNum(tableNum int) int {
+	return tableNum%o.opts.Tables + 1
+}
+
+// eventFuncSecondaryIndex runs the reads of oltp_secondary_index in the same way as oltp_read_only.
+// k is a random number from 1 to --table_size, so a point select by k returns one row on average.
+func (o *OLTPBench) eventFuncSecondaryIndex() func(context.Context) (uint64, uint64, uint64, error) {
+	txOpt := o.txOptions(o.opts.DBDriver == DBDriverSpanner)
+	useTx := o.useTx()
+
+	return func(ctx context.Context) (numReads, numWrites, numOthers uint64, err error) {
+		var tableNum = o.getRandTableNum()
+
+		var inTx bool
+
+		// with --read-hosts, SELECT statements run on the replica without transaction
+		target := o.hostFor(ctx)
+		r := o.readHostFor(ctx)
+		if r != nil {
+			target = r
+		}
+
+		e, err := o.executorFor(ctx, target)
+		if err != nil {
+			return numReads, numWrites, numOthers, err
+		}
+		defer e.release()
+
+		if r == nil && useTx {
+			err = e.begin(ctx, txOpt)
+			if err != nil {
+				return numReads, numWrites, numOthers, err
+			}
+			inTx = true
+			numOthers += 1
+		}
+
+		query := func(stmtName string, args ...any) error {
+			rows, err := e.query(ctx, tableNum, stmtName, args...)
+			if err != nil {
+				e.rollback()
+				return err
+			}
+			for rows.Next() {
+			}
+			rows.Close()
+			numReads += 1
+			return nil
+		}
+
+		readBegin := time.Now()
+
+		for i := 0; i < numIndexPointSelects; i++ {
+			if err = query("stmtIndexPointSelects", sbRand(1, o.opts.TableSize)); err != nil {
+				return numReads, numWrites, numOthers, err
+			}
+		}
+
+		for i := 0; i < numIndexRanges; i++ {
+			begin := sbRand(1, o.opts.TableSize)
+			if err = query("stmtIndexRanges", begin, begin+rangeSize-1); err != nil {
+				return numReads, numWrites, numOthers, err
+			}
+		}
+
+		for i := 0; i < numCoveringRanges; i++ {
+			begin := sbRand(1, o.opts.TableSize)
+			if err = query("stmtCoveringRanges", begin, begin+rangeSize-1); err != nil {
+				return numReads, numWrites, numOthers, err
+			}
+		}
+
+		for i := 0; i < numJoinRanges; i++ {
+			begin := sbRand(1, o.opts.TableSize)
+			if err = query("stmtJoinRanges", begin, begin+rangeSize-1); err != nil {
+				return numReads, numWrites, numOthers, err
+			}
+		}
+
+		if r != nil {
+			r.record(time.Since(readBegin))
+		}
+
+		if inTx {
+			err = e.commit(ctx)
+			if err != nil {
+				return numReads, numWrites, numOthers, err
+			}
+			numOthers += 1
+		}
+
+		return numReads, numWrites, numOthers, nil
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestFormatStmt(t *testing.T) {
+	opts := &BenchmarkOpts{}
+	opts.TablePrefix = defaultTablePrefix
+	opts.Tables = 3
+
+	o := newOLTPBench(opts, rwModeSecondary)
+
+	tests := []struct {
+		stmt     string
+		tableNum int
+		expected string
+	}{
+		{stmtsSecondaryMySQL["stmtIndexPointSelects"], 1, "SELECT id, c FROM sbtest1 WHERE k=?"},
+		{stmtsSecondaryMySQL["stmtJoinRanges"], 1, "SELECT a.id, b.id FROM sbtest1 a JOIN sbtest2 b ON a.k=b.k WHERE a.k BETWEEN ? AND ?"},
+		{stmtsSecondaryPgSQL["stmtJoinRanges"], 3, "SELECT a.id, b.id FROM sbtest3 a JOIN sbtest1 b ON a.k=b.k WHERE a.k BETWEEN $1 AND $2"},
+	}
+
+	for _, tt := range tests {
+		if actual := o.formatStmt(tt.stmt, tt.tableNum); actual != tt.expected {
+			t.Errorf("Expected %q, got %q", tt.expected, actual)
+		}
+	}
+
+	// a table is joined with itself with --tables=1
+	opts.Tables = 1
+	if actual := o.joinTableNum(1); actual != 1 {
+		t.Errorf("Expected 1, got %d", actual)
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	"cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	instance "cloud.google.com/go/spanner/admin/instance/apiv1"
+	"cloud.google.com/go/spanner/admin/instance/apiv1/instancepb"
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/googleapis/go-sql-spanner"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// instance config which the emulator accepts
+	spannerEmulatorConfig = "emulator-config"
+
+	// number of rows written by one Apply(). Spanner allows 80,000 mutations per commit.
+	// https://cloud.google.com/spanner/quotas#limits-for-creating-reading-updating-and-deleting-data
+	spannerMutationBatchSize = 1000
+
+	// interval to poll the progress of long-running DDL
+	spannerDDLPollInterval = 5 * time.Second
+)
+
+var spannerColumns = []string{"id", "k", "c", "pad"}
+
+var spannerPriorities map[string]spannerpb.RequestOptions_Priority = map[string]spannerpb.RequestOptions_Priority{
+	"unspecified": spannerpb.RequestOptions_PRIORITY_UNSPECIFIED,
+	"low":         spannerpb.RequestOptions_PRIORITY_LOW,
+	"medium":      spannerpb.RequestOptions_PRIORITY_MEDIUM,
+	"high":        spannerpb.RequestOptions_PRIORITY_HIGH,
+}
+
+// parseSpannerStaleness parses --spanner-staleness.
+// https://cloud.google.com/spanner/docs/timestamp-bounds
+func parseSpannerStaleness(staleness string) (spanner.TimestampBound, error) {
+	if staleness == "strong" {
+		return spanner.StrongRead(), nil
+	}
+
+	kind, value, found := strings.Cut(staleness, ":")
+	if !found {
+		return spanner.TimestampBound{}, fmt.Errorf("invalid --spanner-staleness: %s", staleness)
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return spanner.TimestampBound{}, fmt.Errorf("invalid --spanner-staleness: %s", staleness)
+	}
+
+	if kind == "exact" {
+		return spanner.ExactStaleness(d), nil
+	} else if kind == "max" {
+		return spanner.MaxStaleness(d), nil
+	}
+	return spanner.TimestampBound{}, fmt.Errorf("invalid --spanner-staleness: %s", staleness)
+}
+
+// setupSpanner validates Spanner specific options and builds options applied to each event.
+func (o *OLTPBench) setupSpanner() error {
+	var err error
+
+	o.spannerStaleness, err = parseSpannerStaleness(o.opts.SpannerStaleness)
+	if err != nil {
+		return err
+	}
+
+	if o.rwMode == rwModeReadWrite && (o.opts.SpannerStaleness != "strong" || o.opts.SpannerReadMode != OptSpannerReadModeTransaction) {
+		return fmt.Errorf("--spanner-staleness and --spanner-read-mode are applicable only to %s", NameOLTPReadOnly)
+	}
+
+	// bounded staleness can be used only in single-use reads
+	if strings.HasPrefix(o.opts.SpannerStaleness, "max:") && o.opts.SpannerReadMode != OptSpannerReadModeSingleUse {
+		return fmt.Errorf("--spanner-staleness=%s requires --spanner-read-mode=%s", o.opts.SpannerStaleness, OptSpannerReadModeSingleUse)
+	}
+
+	priority := spannerPriorities[o.opts.SpannerPriority]
+
+	o.spannerTxOpts = spanner.TransactionOptions{
+		CommitPriority: priority,
+		TransactionTag: o.opts.SpannerRequestTag,
+	}
+
+	if priority != spannerpb.RequestOptions_PRIORITY_UNSPECIFIED || o.opts.SpannerRequestTag != "" {
+		o.spannerExecOpts = &spannerdriver.ExecOptions{
+			QueryOptions: spanner.QueryOptions{
+				Priority:   priority,
+				RequestTag: o.opts.SpannerRequestTag,
+			},
+		}
+	}
+
+	return nil
+}
+
+func (o *OLTPBench) spannerDatabasePath() string {
+	return fmt.Sprintf("projects/%s/instances/%s/databases/%s", o.opts.SpannerProjectId, o.opts.SpannerInstanceId, o.opts.SpannerDB)
+}
+
+// createSpannerInstance creates the instance on the emulator if it does not exist.
+// Admin clients connect to SPANNER_EMULATOR_HOST, which Init() sets in advance.
+func (o *OLTPBench) createSpannerInstance(ctx context.Context) error {
+	client, err := instance.NewInstanceAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	op, err := client.CreateInstance(ctx, &instancepb.CreateInstanceRequest{
+		Parent:     fmt.Sprintf("projects/%s", o.opts.SpannerProjectId),
+		InstanceId: o.opts.SpannerInstanceId,
+		Instance: &instancepb.Instance{
+			Config:      fmt.Sprintf("projects/%s/instanceConfigs/%s", o.opts.SpannerProjectId, spannerEmulatorConfig),
+			DisplayName: o.opts.SpannerInstanceId,
+			NodeCount:   1,
+		},
+	})
+	if status.Code(err) == codes.AlreadyExists {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	fmt.Printf("Creating Spanner instance '%s'...\n", o.opts.SpannerInstanceId)
+	_, err = op.Wait(ctx)
+	if status.Code(err) == codes.AlreadyExists {
+		return nil
+	}
+	return err
+}
+
+// createSpannerDatabase creates the database with --spanner-dialect if it does not exist.
+func (o *OLTPBench) createSpannerDatabase(ctx context.Context) error {
+	client, err := database.NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	req := &databasepb.CreateDatabaseRequest{
+		Parent: fmt.Sprintf("projects/%s/instances/%s", o.opts.SpannerProjectId, o.opts.SpannerInstanceId),
+	}
+	if o.opts.SpannerDialect == OptSpannerDialectPostgreSQL {
+		req.CreateStatement = fmt.Sprintf(`CREATE DATABASE "%s"`, o.opts.SpannerDB)
+		req.DatabaseDialect = databasepb.DatabaseDialect_POSTGRESQL
+	} else {
+		req.CreateStatement = fmt.Sprintf("CREATE DATABASE `%s`", o.opts.SpannerDB)
+		req.DatabaseDialect = databasepb.DatabaseDialect_GOOGLE_STANDARD_SQL
+	}
+
+	op, err := client.CreateDatabase(ctx, req)
+	if status.Code(err) == codes.AlreadyExists {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	fmt.Printf("Creating Spanner database '%s'...\n", o.opts.SpannerDB)
+	_, err = op.Wait(ctx)
+	if status.Code(err) == codes.AlreadyExists {
+		return nil
+	}
+	return err
+}
+
+// insertRowsSpanner writes rows with mutations instead of INSERT statements.
+// Batches are applied in parallel by --spanner-prepare-threads connections.
+func (o *OLTPBench) insertRowsSpanner(ctx context.Context, tableNum int) error {
+	if o.opts.SpannerPrepareThreads < 1 {
+		return fmt.Errorf("--spanner-prepare-threads should be >= 1")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	table := o.tableName(tableNum)
+	batches := make(chan int) // first id of each batch
+	errs := make(chan error, o.opts.SpannerPrepareThreads)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < o.opts.SpannerPrepareThreads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			conn, err := o.db.Conn(ctx)
+			if err != nil {
+				errs <- err
+				cancel()
+				return
+			}
+			defer conn.Close()
+
+			for first := range batches {
+				last := min(first+spannerMutationBatchSize-1, o.opts.TableSize)
+
+				mutations := make([]*spanner.Mutation, 0, last-first+1)
+				for id := first; id <= last; id++ {
+					mutations = append(mutations, spanner.Insert(table, spannerColumns,
+						[]any{int64(id), int64(sbRand(1, o.opts.TableSize)), getCValue(), getPadValue()}))
+				}
+
+				err = conn.Raw(func(driverConn any) error {
+					_, err := driverConn.(spannerdriver.SpannerConn).Apply(ctx, mutations)
+					return err
+				})
+				if err != nil {
+					errs <- err
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+
+loop:
+	for first := 1; first <= o.opts.TableSize; first += spannerMutationBatchSize {
+		select {
+		case batches <- first:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	close(batches)
+
+	wg.Wait()
+	close(errs)
+
+	return <-errs
+}
+
+// createIndexSpanner creates the secondary index as a long-running operation and reports its progress.
+func (o *OLTPBench) createIndexSpanner(ctx context.Context, tableNum int) error {
+	client, err := database.NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	op, err := client.UpdateDatabaseDdl(ctx, &databasepb.UpdateDatabaseDdlRequest{
+		Database:   o.spannerDatabasePath(),
+		Statements: []string{fmt.Sprintf("CREATE INDEX %s ON %s(k)", o.indexName("k", tableNum), o.tableName(tableNum))},
+	})
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(spannerDDLPollInterval)
+	defer ticker.Stop()
+
+	for {
+		err = op.Poll(ctx)
+		if err != nil {
+			return err
+		}
+		if op.Done() {
+			return nil
+		}
+
+		metadata, err := op.Metadata()
+		if err == nil && len(metadata.Progress) > 0 {
+			fmt.Printf("    %d%% completed\n", metadata.Progress[0].ProgressPercent)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// dropTableSpanner drops the table and its secondary index, which has to be dropped first.
+// Their existence is looked up in advance since the emulator may not support IF EXISTS.
+func (o *OLTPBench) dropTableSpanner(ctx context.Context, tableNum int) error {
+	table := o.tableName(tableNum)
+	index := o.indexName("k", tableNum)
+
+	var count int64
+	err := o.db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM information_schema.indexes WHERE table_name = '%s' AND index_name = '%s'", table, index)).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		_, err = o.db.ExecContext(ctx, fmt.Sprintf("DROP INDEX %s", index))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = o.db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM information_schema.tables WHERE table_name = '%s'", table)).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		_, err = o.db.ExecContext(ctx, fmt.Sprintf("DROP TABLE %s", table))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
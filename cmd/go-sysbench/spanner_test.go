@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+
+	"github.com/samitani/go-sysbench"
+)
+
+const (
+	tSpannerProject  = "test-project"
+	tSpannerInstance = "test-instance"
+	tTables          = 2
+	tTableSize       = 1000
+)
+
+// These tests run against a local Spanner emulator.
+//
+//	$ gcloud emulators spanner start
+//	$ SPANNER_EMULATOR_HOST=localhost:9010 go test ./...
+func newSpannerEmulatorOpts(t *testing.T, dialect string) *BenchmarkOpts {
+	t.Helper()
+
+	host := os.Getenv("SPANNER_EMULATOR_HOST")
+	if host == "" {
+		t.Skip("SPANNER_EMULATOR_HOST is not set")
+	}
+
+	opts := &BenchmarkOpts{}
+	opts.Tables = tTables
+	opts.TableSize = tTableSize
+	opts.DBDriver = DBDriverSpanner
+	opts.DBPreparedStmt = OptDBPreparedStmtAuto
+	opts.TablePrefix = "sbtest"
+	opts.CreateSecondary = OptOn
+	opts.PartitionBy = OptPartitionByNone
+	opts.TableCheck = OptOn
+	opts.PgSQLIgnoreErrs = "40P01,23505,40001"
+	opts.SpannerProjectId = tSpannerProject
+	opts.SpannerInstanceId = tSpannerInstance
+	opts.SpannerDB = fmt.Sprintf("sbtest-%s", dialect)
+	opts.SpannerDialect = dialect
+	opts.SpannerEmulator = host
+	opts.SpannerPrepareThreads = 4
+	opts.SpannerStaleness = "strong"
+	opts.SpannerReadMode = OptSpannerReadModeTransaction
+	opts.SpannerPriority = "unspecified"
+	opts.SpannerAutoCreate = OptAutoCreateOn
+
+	return opts
+}
+
+func cleanupSpannerTables(t *testing.T, opts *BenchmarkOpts) {
+	t.Helper()
+
+	runnerOpts := &sysbench.RunnerOpts{Threads: 1}
+	if err := sysbench.NewRunner(runnerOpts, newOLTPBench(opts, rwModeReadWrite)).Cleanup(); err != nil {
+		t.Fatalf("cleanup failed: %s", err)
+	}
+}
+
+func assertSpannerRowCount(t *testing.T, opts *BenchmarkOpts) {
+	t.Helper()
+
+	bench := newOLTPBench(opts, rwModeReadOnly)
+	if err := bench.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer bench.Done()
+
+	for tableNum := 1; tableNum <= opts.Tables; tableNum++ {
+		var count int
+		if err := bench.db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", bench.tableName(tableNum))).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if count != opts.TableSize {
+			t.Errorf("Expected %d rows in %s, got %d", opts.TableSize, bench.tableName(tableNum), count)
+		}
+	}
+}
+
+// resultRecorder keeps the result which the runner reports, since Run() returns nil even when an event fails.
+type resultRecorder struct {
+	*OLTPBench
+	result *sysbench.Result
+}
+
+func (r *resultRecorder) Report(result *sysbench.Result) {
+	r.OLTPBench.Report(result)
+	r.result = result
+}
+
+// assertSpannerRun runs the benchmark and asserts that all the events completed.
+// A failing event cancels the run, so fewer events complete.
+func assertSpannerRun(t *testing.T, runnerOpts *sysbench.RunnerOpts, bench *OLTPBench, desc string) {
+	t.Helper()
+
+	rec := &resultRecorder{OLTPBench: bench}
+	if err := sysbench.NewRunner(runnerOpts, rec).Run(); err != nil {
+		t.Fatalf("run failed (%s): %s", desc, err)
+	}
+	if rec.result == nil {
+		t.Fatalf("run did not report the result (%s)", desc)
+	}
+	if rec.result.TotalEvents != runnerOpts.Events {
+		t.Errorf("Expected %d events (%s), got %d", runnerOpts.Events, desc, rec.result.TotalEvents)
+	}
+	if rec.result.TotalReads == 0 {
+		t.Errorf("Expected reads (%s), got none", desc)
+	}
+	if bench.rwMode == rwModeReadWrite && rec.result.TotalWrites == 0 {
+		t.Errorf("Expected writes (%s), got none", desc)
+	}
+}
+
+func testSpannerEmulator(t *testing.T, dialect string) {
+	opts := newSpannerEmulatorOpts(t, dialect)
+	cleanupSpannerTables(t, opts)
+	t.Cleanup(func() { cleanupSpannerTables(t, opts) })
+
+	// a single thread so that no transaction is aborted by the emulator, and all the events complete
+	runnerOpts := &sysbench.RunnerOpts{Threads: 1, Events: 100, Time: 60, Histogram: "off", Percentile: 95}
+
+	if err := sysbench.NewRunner(runnerOpts, newOLTPBench(opts, rwModeReadWrite)).Prepare(); err != nil {
+		t.Fatalf("prepare failed: %s", err)
+	}
+	assertSpannerRowCount(t, opts)
+	if err := sysbench.NewRunner(runnerOpts, newOLTPBench(opts, rwModeReadWrite)).Check(); err != nil {
+		t.Fatalf("check failed: %s", err)
+	}
+
+	for _, mode := range []string{rwModeReadOnly, rwModeReadWrite} {
+		for _, psMode := range []string{OptDBPreparedStmtAuto, OptDBPreparedStmtDisable} {
+			opts.DBPreparedStmt = psMode
+			assertSpannerRun(t, runnerOpts, newOLTPBench(opts, mode), fmt.Sprintf("mode: %s, db-ps-mode: %s", mode, psMode))
+		}
+	}
+	assertSpannerRowCount(t, opts)
+
+	opts.SpannerStaleness = "max:10s"
+	opts.SpannerReadMode = OptSpannerReadModeSingleUse
+	opts.SpannerPriority = "low"
+	opts.SpannerRequestTag = "go-sysbench"
+	assertSpannerRun(t, runnerOpts, newOLTPBench(opts, rwModeReadOnly), "single-use reads")
+}
+
+func TestSpannerEmulatorGoogleSQL(t *testing.T) {
+	testSpannerEmulator(t, OptSpannerDialectGoogleSQL)
+}
+
+func TestSpannerEmulatorPostgreSQL(t *testing.T) {
+	testSpannerEmulator(t, OptSpannerDialectPostgreSQL)
+}
+
+func TestParseSpannerStaleness(t *testing.T) {
+	valid := map[string]spanner.TimestampBound{
+		"strong":     spanner.StrongRead(),
+		"exact:15s":  spanner.ExactStaleness(15 * time.Second),
+		"max:500ms":  spanner.MaxStaleness(500 * time.Millisecond),
+		"exact:1m0s": spanner.ExactStaleness(time.Minute),
+	}
+	for input, expected := range valid {
+		tb, err := parseSpannerStaleness(input)
+		if err != nil {
+			t.Errorf("Expected %s to be valid, got %s", input, err)
+		}
+		if tb.String() != expected.String() {
+			t.Errorf("Expected %s, got %s", expected, tb)
+		}
+	}
+
+	for _, input := range []string{"", "weak", "exact", "exact:10", "min:10s"} {
+		if _, err := parseSpannerStaleness(input); err == nil {
+			t.Errorf("Expected %q to be invalid", input)
+		}
+	}
+}
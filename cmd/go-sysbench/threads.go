@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/samitani/go-sysbench"
+)
+
+const NameThreads = "threads"
+
+type (
+	ThreadsOpts struct {
+		ThreadYields int `long:"thread-yields" description:"number of yields to do per request" default:"1000"`
+		ThreadLocks  int `long:"thread-locks" description:"number of locks per thread" default:"8"`
+	}
+
+	// ThreadsBench is equivalent to sysbench threads. Each event yields the goroutine while holding a mutex.
+	// https://github.com/akopytov/sysbench/blob/1.0.20/src/tests/threads/sb_threads.c
+	ThreadsBench struct {
+		opts  *ThreadsOpts
+		locks []sync.Mutex
+	}
+)
+
+func newThreadsBench(option *ThreadsOpts) *ThreadsBench {
+	return &ThreadsBench{opts: option}
+}
+
+func (t *ThreadsBench) Init(ctx context.Context) error {
+	if t.opts.ThreadLocks < 1 {
+		return fmt.Errorf("--thread-locks should be >= 1")
+	}
+	t.locks = make([]sync.Mutex, t.opts.ThreadLocks)
+	return nil
+}
+
+func (t *ThreadsBench) Done() error {
+	return nil
+}
+
+func (t *ThreadsBench) Prepare(ctx context.Context) error {
+	return fmt.Errorf("'%s' test does not implement the 'prepare' command", NameThreads)
+}
+
+func (t *ThreadsBench) PreEvent(ctx context.Context) error {
+	return nil
+}
+
+func (t *ThreadsBench) Event(ctx context.Context) (numReads, numWrites, numOthers, numIgnoredErros uint64, err error) {
+	lock := &t.locks[t.lockNum(sysbench.ThreadID(ctx))]
+
+	for i := 0; i < t.opts.ThreadYields; i++ {
+		lock.Lock()
+		runtime.Gosched()
+		lock.Unlock()
+	}
+	return 0, 0, 0, 0, nil
+}
+
+// lockNum returns the lock of the thread. Threads share a lock when --threads is greater than --thread-locks, as in sysbench.
+func (t *ThreadsBench) lockNum(threadID int) int {
+	return threadID % len(t.locks)
+}
+
+// Summary prints nothing, since sysbench threads reports only the general statistics.
+func (t *ThreadsBench) Summary(result *sysbench.Result) {
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/samitani/go-sysbench"
+)
+
+func TestThreadsBenchLockNum(t *testing.T) {
+	bench := newThreadsBench(&ThreadsOpts{ThreadYields: 10, ThreadLocks: 3})
+	if err := bench.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for threadID, expected := range []int{0, 1, 2, 0, 1} {
+		if actual := bench.lockNum(threadID); actual != expected {
+			t.Errorf("Expected lock %d for thread %d, got %d", expected, threadID, actual)
+		}
+	}
+
+	if err := newThreadsBench(&ThreadsOpts{ThreadYields: 10, ThreadLocks: 0}).Init(context.Background()); err == nil {
+		t.Errorf("Expected --thread-locks=0 to be invalid")
+	}
+}
+
+func TestThreadsBenchRun(t *testing.T) {
+	runnerOpts := &sysbench.RunnerOpts{Threads: 4, Events: 100, Time: 60, Histogram: "off", Percentile: 95}
+	bench := newThreadsBench(&ThreadsOpts{ThreadYields: 10, ThreadLocks: 2})
+
+	if err := sysbench.NewRunner(runnerOpts, bench).Run(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,1032 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/samitani/go-sysbench"
+)
+
+const (
+	NameTPCC = "tpcc"
+
+	// https://www.tpc.org/tpc_documents_current_versions/pdf/tpc-c_v5.11.0.pdf
+	// 4.3.3.1 Table Population Requirements
+	tpccItems                = 100000
+	tpccDistrictsPerWH       = 10
+	tpccCustomersPerDistrict = 3000
+	tpccOrdersPerDistrict    = 3000
+	// orders from this id are undelivered and have rows in new_orders
+	tpccFirstNewOrder = 2101
+
+	// number of rows per INSERT statement in prepare
+	tpccInsertBatch = 500
+
+	tpccNewOrder    = "new_order"
+	tpccPayment     = "payment"
+	tpccOrderStatus = "order_status"
+	tpccDelivery    = "delivery"
+	tpccStockLevel  = "stock_level"
+
+	// statements of all the tables are registered as those of table 1 of the executor
+	tpccTableNum = 1
+)
+
+// 5.2.3 percentages of the mix, which add up to 100. Payment, Order-Status, Delivery and Stock-Level
+// are at their minimum percentages, and New-Order has the remaining 45%. tpccPickTransaction picks by these weights.
+var tpccMix = []struct {
+	name    string
+	percent int
+}{
+	{tpccNewOrder, 45},
+	{tpccPayment, 43},
+	{tpccOrderStatus, 4},
+	{tpccDelivery, 4},
+	{tpccStockLevel, 4},
+}
+
+// statements of the transactions. s_dist_xx of the district is selected by selectStockXX.
+var tpccStmts map[string]string = map[string]string{
+	"selectWarehouseTax":            "SELECT w_tax FROM warehouse WHERE w_id=?",
+	"selectDistrictForUpdate":       "SELECT d_tax, d_next_o_id FROM district WHERE d_w_id=? AND d_id=? FOR UPDATE",
+	"updateDistrictNextOrder":       "UPDATE district SET d_next_o_id=d_next_o_id+1 WHERE d_w_id=? AND d_id=?",
+	"selectCustomerDiscount":        "SELECT c_discount, c_last, c_credit FROM customer WHERE c_w_id=? AND c_d_id=? AND c_id=?",
+	"insertOrder":                   "INSERT INTO orders (o_id, o_d_id, o_w_id, o_c_id, o_entry_d, o_ol_cnt, o_all_local) VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP, ?, ?)",
+	"insertNewOrder":                "INSERT INTO new_orders (no_o_id, no_d_id, no_w_id) VALUES (?, ?, ?)",
+	"selectItem":                    "SELECT i_price, i_name, i_data FROM item WHERE i_id=?",
+	"updateStock":                   "UPDATE stock SET s_quantity=?, s_ytd=s_ytd+?, s_order_cnt=s_order_cnt+1, s_remote_cnt=s_remote_cnt+? WHERE s_i_id=? AND s_w_id=?",
+	"insertOrderLine":               "INSERT INTO order_line (ol_o_id, ol_d_id, ol_w_id, ol_number, ol_i_id, ol_supply_w_id, ol_quantity, ol_amount, ol_dist_info) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+	"updateWarehouseYtd":            "UPDATE warehouse SET w_ytd=w_ytd+? WHERE w_id=?",
+	"selectWarehouseName":           "SELECT w_name FROM warehouse WHERE w_id=?",
+	"updateDistrictYtd":             "UPDATE district SET d_ytd=d_ytd+? WHERE d_w_id=? AND d_id=?",
+	"selectDistrictName":            "SELECT d_name FROM district WHERE d_w_id=? AND d_id=?",
+	"selectCustomerCreditForUpdate": "SELECT c_credit FROM customer WHERE c_w_id=? AND c_d_id=? AND c_id=? FOR UPDATE",
+	"selectCustomerData":            "SELECT c_data FROM customer WHERE c_w_id=? AND c_d_id=? AND c_id=?",
+	"updateCustomerPaymentBC":       "UPDATE customer SET c_balance=c_balance-?, c_ytd_payment=c_ytd_payment+?, c_payment_cnt=c_payment_cnt+1, c_data=? WHERE c_w_id=? AND c_d_id=? AND c_id=?",
+	"updateCustomerPayment":         "UPDATE customer SET c_balance=c_balance-?, c_ytd_payment=c_ytd_payment+?, c_payment_cnt=c_payment_cnt+1 WHERE c_w_id=? AND c_d_id=? AND c_id=?",
+	"insertHistory":                 "INSERT INTO history (h_c_id, h_c_d_id, h_c_w_id, h_d_id, h_w_id, h_date, h_amount, h_data) VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP, ?, ?)",
+	"selectCustomerBalance":         "SELECT c_balance, c_first, c_middle, c_last FROM customer WHERE c_w_id=? AND c_d_id=? AND c_id=?",
+	"selectLastOrder":               "SELECT o_id, o_entry_d, o_carrier_id FROM orders WHERE o_w_id=? AND o_d_id=? AND o_c_id=? ORDER BY o_id DESC LIMIT 1",
+	"selectOrderLines":              "SELECT ol_i_id, ol_supply_w_id, ol_quantity, ol_amount, ol_delivery_d FROM order_line WHERE ol_w_id=? AND ol_d_id=? AND ol_o_id=?",
+	"selectNewOrderForUpdate":       "SELECT no_o_id FROM new_orders WHERE no_w_id=? AND no_d_id=? ORDER BY no_o_id LIMIT 1 FOR UPDATE",
+	"deleteNewOrder":                "DELETE FROM new_orders WHERE no_w_id=? AND no_d_id=? AND no_o_id=?",
+	"selectOrderCustomer":           "SELECT o_c_id FROM orders WHERE o_w_id=? AND o_d_id=? AND o_id=?",
+	"updateOrderCarrier":            "UPDATE orders SET o_carrier_id=? WHERE o_w_id=? AND o_d_id=? AND o_id=?",
+	"updateOrderLineDelivery":       "UPDATE order_line SET ol_delivery_d=CURRENT_TIMESTAMP WHERE ol_w_id=? AND ol_d_id=? AND ol_o_id=?",
+	"selectOrderLineAmount":         "SELECT SUM(ol_amount) FROM order_line WHERE ol_w_id=? AND ol_d_id=? AND ol_o_id=?",
+	"updateCustomerDelivery":        "UPDATE customer SET c_balance=c_balance+?, c_delivery_cnt=c_delivery_cnt+1 WHERE c_w_id=? AND c_d_id=? AND c_id=?",
+	"selectDistrictNextOrder":       "SELECT d_next_o_id FROM district WHERE d_w_id=? AND d_id=?",
+	"selectLowStock":                "SELECT COUNT(DISTINCT s_i_id) FROM order_line, stock WHERE ol_w_id=? AND ol_d_id=? AND ol_o_id>=? AND ol_o_id<? AND s_w_id=ol_w_id AND s_i_id=ol_i_id AND s_quantity<?",
+	"selectCustomersByLastName":     "SELECT c_id FROM customer WHERE c_w_id=? AND c_d_id=? AND c_last=? ORDER BY c_first",
+}
+
+// tables in the order of creation
+var tpccTables = []string{"warehouse", "district", "customer", "history", "new_orders", "orders", "order_line", "item", "stock"}
+
+// 4.3.2.3 syllables of C_LAST
+var tpccSyllables = []string{"BAR", "OUGHT", "ABLE", "PRI", "PRES", "ESE", "ANTI", "CALLY", "ATION", "EING"}
+
+// 2.1.6 constant C of NURand, chosen once per process
+var (
+	tpccCLast = rand.Intn(256)
+	tpccCId   = rand.Intn(1024)
+	tpccCItem = rand.Intn(8192)
+)
+
+// errTPCCRollback is returned by New-Order transactions which roll back on purpose by an unused item number.
+var errTPCCRollback = errors.New("new order rolled back by an unused item")
+
+type (
+	TPCCOpts struct {
+		Warehouses int `long:"warehouses" description:"number of warehouses" default:"1"`
+	}
+
+	// TPCCBench is a TPC-C like workload on the hosts of OLTPBench.
+	// It is not a certified implementation. Keying and think times are not emulated,
+	// and each event runs one of the five transactions in the standard mix.
+	TPCCBench struct {
+		*OLTPBench
+
+		stats     map[string]*latencyStats
+		newOrders atomic.Uint64 // committed New-Order transactions
+		rollbacks atomic.Uint64 // New-Order transactions rolled back by an unused item
+	}
+
+	// tpccTx runs statements of a transaction by name through the executor of --db-ps-mode, and counts them.
+	tpccTx struct {
+		ctx context.Context
+		e   stmtExecutor
+
+		numReads, numWrites, numOthers uint64
+	}
+)
+
+func newTPCCBench(option *BenchmarkOpts) *TPCCBench {
+	stats := make(map[string]*latencyStats)
+	for _, m := range tpccMix {
+		s := newLatencyStats()
+		stats[m.name] = &s
+	}
+	return &TPCCBench{OLTPBench: newOLTPBench(option, rwModeReadWrite), stats: stats}
+}
+
+func (t *TPCCBench) Init(ctx context.Context) error {
+	if t.opts.DBDriver == DBDriverSpanner {
+		return fmt.Errorf("'%s' test is not supported by %s driver", NameTPCC, DBDriverSpanner)
+	}
+	if t.opts.Warehouses <= 0 {
+		return fmt.Errorf("Invalid value for warehouses: %d", t.opts.Warehouses)
+	}
+	// reads have to see the writes of the transaction
+	if t.opts.ReadHosts != "" {
+		return fmt.Errorf("--read-hosts is not supported by '%s' test", NameTPCC)
+	}
+	if t.opts.SkipTrx == OptOn {
+		return fmt.Errorf("--skip-trx is not supported by '%s' test", NameTPCC)
+	}
+	return t.OLTPBench.Init(ctx)
+}
+
+func (t *TPCCBench) PreEvent(ctx context.Context) error {
+	var err error
+
+	if t.opts.TableCheck == OptOn {
+		var warehouses int
+		err = t.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM warehouse").Scan(&warehouses)
+		if err != nil {
+			return fmt.Errorf("table 'warehouse' is not accessible. run prepare first: %w", err)
+		}
+		if warehouses != t.opts.Warehouses {
+			return fmt.Errorf("number of warehouses is %d, which does not match --warehouses=%d. prepare and run should use the same --warehouses", warehouses, t.opts.Warehouses)
+		}
+	}
+
+	err = t.printIsolation(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(t.lagHosts) > 0 {
+		err = t.createHeartbeat(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	stmts := make(map[string]string)
+	for stmtName, stmtString := range tpccStmts {
+		stmts[stmtName] = stmtString
+	}
+	for dId := 1; dId <= tpccDistrictsPerWH; dId++ {
+		stmts[fmt.Sprintf("selectStock%02d", dId)] = fmt.Sprintf("SELECT s_quantity, s_dist_%02d FROM stock WHERE s_i_id=? AND s_w_id=? FOR UPDATE", dId)
+	}
+	if t.opts.DBDriver == DBDriverPgSQL {
+		for stmtName, stmtString := range stmts {
+			stmts[stmtName] = rebindPgSQL(stmtString)
+		}
+	}
+	err = t.prepareStmts(ctx, map[int]map[string]string{tpccTableNum: stmts}, nil)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Warehouses: %d\n\n", t.opts.Warehouses)
+
+	if t.opts.ServerMetrics != "" {
+		err = t.startServerMetrics(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *TPCCBench) Event(ctx context.Context) (numReads, numWrites, numOthers, numIgnoredErros uint64, err error) {
+	name := tpccPickTransaction(sbRand(1, 100))
+	wId := sbRand(1, t.opts.Warehouses)
+
+	var txFunc func(*tpccTx, int) error
+	switch name {
+	case tpccNewOrder:
+		txFunc = t.newOrder
+	case tpccPayment:
+		txFunc = t.payment
+	case tpccOrderStatus:
+		txFunc = t.orderStatus
+	case tpccDelivery:
+		txFunc = t.delivery
+	case tpccStockLevel:
+		txFunc = t.stockLevel
+	}
+
+	begin := time.Now()
+	numReads, numWrites, numOthers, numIgnoredErros, err = t.runEvent(ctx, func(ctx context.Context) (uint64, uint64, uint64, error) {
+		e, err := t.executorFor(ctx, t.hostFor(ctx))
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		defer e.release()
+
+		err = e.begin(ctx, t.txOptions(false))
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		ttx := &tpccTx{ctx: ctx, e: e, numOthers: 1}
+
+		err = txFunc(ttx, wId)
+		if errors.Is(err, errTPCCRollback) {
+			// 2.4.2.3 the rollback is a part of the transaction profile, not a failure
+			e.rollback()
+			t.rollbacks.Add(1)
+			return ttx.numReads, ttx.numWrites, ttx.numOthers + 1, nil
+		}
+		if err != nil {
+			e.rollback()
+			return ttx.numReads, ttx.numWrites, ttx.numOthers, err
+		}
+
+		err = e.commit(ctx)
+		if err != nil {
+			return ttx.numReads, ttx.numWrites, ttx.numOthers, err
+		}
+		if name == tpccNewOrder {
+			t.newOrders.Add(1)
+		}
+		return ttx.numReads, ttx.numWrites, ttx.numOthers + 1, nil
+	})
+
+	if err == nil && numIgnoredErros == 0 {
+		t.stats[name].record(time.Since(begin))
+	}
+	return numReads, numWrites, numOthers, numIgnoredErros, err
+}
+
+// tpccPickTransaction returns the transaction for a random number from 1 to 100.
+func tpccPickTransaction(n int) string {
+	for _, m := range tpccMix {
+		if n <= m.percent {
+			return m.name
+		}
+		n -= m.percent
+	}
+	return tpccNewOrder
+}
+
+// 2.4 The New-Order Transaction
+func (t *TPCCBench) newOrder(tx *tpccTx, wId int) error {
+	dId := sbRand(1, tpccDistrictsPerWH)
+	cId := nuRand(1023, tpccCId, 1, tpccCustomersPerDistrict)
+	olCnt := sbRand(5, 15)
+	rbk := sbRand(1, 100)
+
+	itemIds := make([]int, olCnt)
+	supplyWIds := make([]int, olCnt)
+	allLocal := 1
+	for i := range itemIds {
+		itemIds[i] = nuRand(8191, tpccCItem, 1, tpccItems)
+		supplyWIds[i] = wId
+		if t.opts.Warehouses > 1 && sbRand(1, 100) == 1 {
+			supplyWIds[i] = t.otherWarehouse(wId)
+			allLocal = 0
+		}
+	}
+	if rbk == 1 {
+		itemIds[olCnt-1] = tpccItems + 1
+	}
+
+	var wTax, dTax, cDiscount float64
+	var oId int
+	var cLast, cCredit string
+
+	err := tx.queryRow("selectWarehouseTax", wId).Scan(&wTax)
+	if err != nil {
+		return err
+	}
+	err = tx.queryRow("selectDistrictForUpdate", wId, dId).Scan(&dTax, &oId)
+	if err != nil {
+		return err
+	}
+	err = tx.exec("updateDistrictNextOrder", wId, dId)
+	if err != nil {
+		return err
+	}
+	err = tx.queryRow("selectCustomerDiscount", wId, dId, cId).Scan(&cDiscount, &cLast, &cCredit)
+	if err != nil {
+		return err
+	}
+	err = tx.exec("insertOrder", oId, dId, wId, cId, olCnt, allLocal)
+	if err != nil {
+		return err
+	}
+	err = tx.exec("insertNewOrder", oId, dId, wId)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < olCnt; i++ {
+		var iPrice float64
+		var iName, iData string
+		quantity := sbRand(1, 10)
+
+		err = tx.queryRow("selectItem", itemIds[i]).Scan(&iPrice, &iName, &iData)
+		if err == sql.ErrNoRows {
+			return errTPCCRollback
+		}
+		if err != nil {
+			return err
+		}
+
+		var sQuantity int
+		var sDistInfo string
+		err = tx.queryRow(fmt.Sprintf("selectStock%02d", dId), itemIds[i], supplyWIds[i]).Scan(&sQuantity, &sDistInfo)
+		if err != nil {
+			return err
+		}
+
+		if sQuantity >= quantity+10 {
+			sQuantity -= quantity
+		} else {
+			sQuantity += 91 - quantity
+		}
+		remote := 0
+		if supplyWIds[i] != wId {
+			remote = 1
+		}
+		err = tx.exec("updateStock", sQuantity, quantity, remote, itemIds[i], supplyWIds[i])
+		if err != nil {
+			return err
+		}
+
+		amount := float64(quantity) * iPrice * (1 + wTax + dTax) * (1 - cDiscount)
+		err = tx.exec("insertOrderLine",
+			oId, dId, wId, i+1, itemIds[i], supplyWIds[i], quantity, fmt.Sprintf("%.2f", amount), sDistInfo)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 2.5 The Payment Transaction
+func (t *TPCCBench) payment(tx *tpccTx, wId int) error {
+	dId := sbRand(1, tpccDistrictsPerWH)
+	cWId, cDId := wId, dId
+	if t.opts.Warehouses > 1 && sbRand(1, 100) > 85 {
+		cWId = t.otherWarehouse(wId)
+		cDId = sbRand(1, tpccDistrictsPerWH)
+	}
+	amount := fmt.Sprintf("%.2f", float64(sbRand(100, 500000))/100)
+
+	var wName, dName string
+
+	err := tx.exec("updateWarehouseYtd", amount, wId)
+	if err != nil {
+		return err
+	}
+	err = tx.queryRow("selectWarehouseName", wId).Scan(&wName)
+	if err != nil {
+		return err
+	}
+	err = tx.exec("updateDistrictYtd", amount, wId, dId)
+	if err != nil {
+		return err
+	}
+	err = tx.queryRow("selectDistrictName", wId, dId).Scan(&dName)
+	if err != nil {
+		return err
+	}
+
+	cId, err := t.selectCustomer(tx, cWId, cDId)
+	if err != nil {
+		return err
+	}
+
+	var cCredit string
+	err = tx.queryRow("selectCustomerCreditForUpdate", cWId, cDId, cId).Scan(&cCredit)
+	if err != nil {
+		return err
+	}
+
+	if cCredit == "BC" {
+		var cData string
+		err = tx.queryRow("selectCustomerData", cWId, cDId, cId).Scan(&cData)
+		if err != nil {
+			return err
+		}
+		cData = fmt.Sprintf("%d %d %d %d %d %s|%s", cId, cDId, cWId, dId, wId, amount, cData)
+		if len(cData) > 500 {
+			cData = cData[:500]
+		}
+		err = tx.exec("updateCustomerPaymentBC", amount, amount, cData, cWId, cDId, cId)
+	} else {
+		err = tx.exec("updateCustomerPayment", amount, amount, cWId, cDId, cId)
+	}
+	if err != nil {
+		return err
+	}
+
+	hData := wName + "    " + dName
+	return tx.exec("insertHistory", cId, cDId, cWId, dId, wId, amount, hData)
+}
+
+// 2.6 The Order-Status Transaction
+func (t *TPCCBench) orderStatus(tx *tpccTx, wId int) error {
+	dId := sbRand(1, tpccDistrictsPerWH)
+
+	cId, err := t.selectCustomer(tx, wId, dId)
+	if err != nil {
+		return err
+	}
+
+	var cBalance float64
+	var cFirst, cMiddle, cLast string
+	err = tx.queryRow("selectCustomerBalance", wId, dId, cId).Scan(&cBalance, &cFirst, &cMiddle, &cLast)
+	if err != nil {
+		return err
+	}
+
+	var oId int
+	var oEntryD, oCarrierId any
+	err = tx.queryRow("selectLastOrder", wId, dId, cId).Scan(&oId, &oEntryD, &oCarrierId)
+	if err == sql.ErrNoRows {
+		// the customer has not ordered yet
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.queryAll("selectOrderLines", wId, dId, oId)
+}
+
+// 2.7 The Delivery Transaction. Districts are processed in one database transaction.
+func (t *TPCCBench) delivery(tx *tpccTx, wId int) error {
+	carrierId := sbRand(1, 10)
+
+	for dId := 1; dId <= tpccDistrictsPerWH; dId++ {
+		var oId, cId int
+		var amount float64
+
+		err := tx.queryRow("selectNewOrderForUpdate", wId, dId).Scan(&oId)
+		if err == sql.ErrNoRows {
+			// 2.7.4.2 the district is skipped if no outstanding order
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		err = tx.exec("deleteNewOrder", wId, dId, oId)
+		if err != nil {
+			return err
+		}
+		err = tx.queryRow("selectOrderCustomer", wId, dId, oId).Scan(&cId)
+		if err != nil {
+			return err
+		}
+		err = tx.exec("updateOrderCarrier", carrierId, wId, dId, oId)
+		if err != nil {
+			return err
+		}
+		err = tx.exec("updateOrderLineDelivery", wId, dId, oId)
+		if err != nil {
+			return err
+		}
+		err = tx.queryRow("selectOrderLineAmount", wId, dId, oId).Scan(&amount)
+		if err != nil {
+			return err
+		}
+		err = tx.exec("updateCustomerDelivery", fmt.Sprintf("%.2f", amount), wId, dId, cId)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 2.8 The Stock-Level Transaction
+func (t *TPCCBench) stockLevel(tx *tpccTx, wId int) error {
+	dId := sbRand(1, tpccDistrictsPerWH)
+	threshold := sbRand(10, 20)
+
+	var nextOId, lowStock int
+	err := tx.queryRow("selectDistrictNextOrder", wId, dId).Scan(&nextOId)
+	if err != nil {
+		return err
+	}
+
+	return tx.queryRow("selectLowStock", wId, dId, nextOId-20, nextOId, threshold).Scan(&lowStock)
+}
+
+// selectCustomer returns a customer id, selected by last name in 60% and by id in 40% of the cases.
+// 2.5.2.2 customers with the last name are sorted by c_first, and the one at position n/2 rounded up is used.
+func (t *TPCCBench) selectCustomer(tx *tpccTx, wId int, dId int) (int, error) {
+	if sbRand(1, 100) > 60 {
+		return nuRand(1023, tpccCId, 1, tpccCustomersPerDistrict), nil
+	}
+
+	cLast := tpccLastName(nuRand(255, tpccCLast, 0, 999))
+	rows, err := tx.query("selectCustomersByLastName", wId, dId, cLast)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, fmt.Errorf("no customer with c_last=%s in warehouse %d district %d", cLast, wId, dId)
+	}
+	return ids[(len(ids)+1)/2-1], nil
+}
+
+func (t *TPCCBench) otherWarehouse(wId int) int {
+	other := sbRand(1, t.opts.Warehouses-1)
+	if other >= wId {
+		other++
+	}
+	return other
+}
+
+// Report prints tpmC and latency per transaction type after the statistics of OLTPBench.
+func (t *TPCCBench) Report(result *sysbench.Result) {
+	t.OLTPBench.Report(result)
+
+	fmt.Println("\nTPC-C statistics:")
+	fmt.Printf("    tpmC (New-Order per minute): %14.2f\n", float64(t.newOrders.Load())*60/result.TotalTime.Seconds())
+	fmt.Printf("    New-Order rolled back:       %14d\n", t.rollbacks.Load())
+	for _, m := range tpccMix {
+		t.stats[m.name].print(m.name, "transactions", result)
+	}
+}
+
+// Check verifies the number of rows and the consistency conditions of 3.3.2.
+func (t *TPCCBench) Check(ctx context.Context) error {
+	checks := []struct {
+		title    string
+		query    string
+		expected int
+	}{
+		{"number of warehouses", "SELECT COUNT(*) FROM warehouse", t.opts.Warehouses},
+		{"number of districts", "SELECT COUNT(*) FROM district", t.opts.Warehouses * tpccDistrictsPerWH},
+		{"number of items", "SELECT COUNT(*) FROM item", tpccItems},
+		{"number of stock", "SELECT COUNT(*) FROM stock", t.opts.Warehouses * tpccItems},
+		// consistency condition 1: W_YTD = sum(D_YTD)
+		{"warehouses whose w_ytd differs from sum of d_ytd", "SELECT COUNT(*) FROM warehouse WHERE w_ytd <> (SELECT SUM(d_ytd) FROM district WHERE d_w_id=w_id)", 0},
+		// consistency condition 2: D_NEXT_O_ID - 1 = max(O_ID) = max(NO_O_ID)
+		{"districts whose d_next_o_id does not follow max(o_id)", "SELECT COUNT(*) FROM district WHERE d_next_o_id - 1 <> (SELECT MAX(o_id) FROM orders WHERE o_w_id=d_w_id AND o_d_id=d_id)", 0},
+		{"districts whose d_next_o_id does not follow max(no_o_id)", "SELECT COUNT(*) FROM district WHERE d_next_o_id - 1 <> (SELECT MAX(no_o_id) FROM new_orders WHERE no_w_id=d_w_id AND no_d_id=d_id)", 0},
+	}
+
+	var failed int
+	for _, c := range checks {
+		var n int
+		err := t.db.QueryRowContext(ctx, c.query).Scan(&n)
+		if err != nil {
+			return fmt.Errorf("failed to check %s: %w", c.title, err)
+		}
+		if n != c.expected {
+			fmt.Printf("    %s is %d, expected %d\n", c.title, n, c.expected)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed with --warehouses=%d", failed, len(checks), t.opts.Warehouses)
+	}
+	fmt.Println("All tables are consistent")
+
+	return nil
+}
+
+// Cleanup drops the tables created by prepare.
+func (t *TPCCBench) Cleanup(ctx context.Context) error {
+	for _, table := range append(tpccTables, t.heartbeatTable()) {
+		fmt.Printf("Dropping table '%s'...\n", table)
+		_, err := t.db.ExecContext(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s", table))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *TPCCBench) Prepare(ctx context.Context) error {
+	err := t.createTPCCTables(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Inserting %d records into 'item'\n", tpccItems)
+	err = t.loadItems(ctx)
+	if err != nil {
+		return err
+	}
+
+	for wId := 1; wId <= t.opts.Warehouses; wId++ {
+		fmt.Printf("Loading warehouse %d\n", wId)
+		err = t.loadWarehouse(ctx, wId)
+		if err != nil {
+			return err
+		}
+	}
+
+	// secondary indexes used by the transactions by customer last name and Order-Status
+	fmt.Println("Creating a secondary index on 'customer'...")
+	_, err = t.db.ExecContext(ctx, "CREATE INDEX idx_customer ON customer (c_w_id, c_d_id, c_last, c_first)")
+	if err != nil {
+		return err
+	}
+	fmt.Println("Creating a secondary index on 'orders'...")
+	_, err = t.db.ExecContext(ctx, "CREATE INDEX idx_orders ON orders (o_w_id, o_d_id, o_c_id, o_id)")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *TPCCBench) createTPCCTables(ctx context.Context) error {
+	var tsDef, engineDef string
+	if t.opts.DBDriver == DBDriverMySQL {
+		tsDef = "DATETIME"
+		engineDef = fmt.Sprintf("/*! ENGINE = %s */", t.opts.MySQLEngine)
+	} else {
+		tsDef = "TIMESTAMP"
+	}
+
+	var stockDists string
+	for i := 1; i <= tpccDistrictsPerWH; i++ {
+		stockDists += fmt.Sprintf("s_dist_%02d CHAR(24) NOT NULL,\n", i)
+	}
+
+	// 1.3 Table Layouts
+	defs := map[string]string{
+		"warehouse": `w_id INT NOT NULL,
+			w_name VARCHAR(10) NOT NULL,
+			w_street_1 VARCHAR(20) NOT NULL,
+			w_street_2 VARCHAR(20) NOT NULL,
+			w_city VARCHAR(20) NOT NULL,
+			w_state CHAR(2) NOT NULL,
+			w_zip CHAR(9) NOT NULL,
+			w_tax DECIMAL(4,4) NOT NULL,
+			w_ytd DECIMAL(12,2) NOT NULL,
+			PRIMARY KEY (w_id)`,
+		"district": `d_id INT NOT NULL,
+			d_w_id INT NOT NULL,
+			d_name VARCHAR(10) NOT NULL,
+			d_street_1 VARCHAR(20) NOT NULL,
+			d_street_2 VARCHAR(20) NOT NULL,
+			d_city VARCHAR(20) NOT NULL,
+			d_state CHAR(2) NOT NULL,
+			d_zip CHAR(9) NOT NULL,
+			d_tax DECIMAL(4,4) NOT NULL,
+			d_ytd DECIMAL(12,2) NOT NULL,
+			d_next_o_id INT NOT NULL,
+			PRIMARY KEY (d_w_id, d_id)`,
+		"customer": `c_id INT NOT NULL,
+			c_d_id INT NOT NULL,
+			c_w_id INT NOT NULL,
+			c_first VARCHAR(16) NOT NULL,
+			c_middle CHAR(2) NOT NULL,
+			c_last VARCHAR(16) NOT NULL,
+			c_street_1 VARCHAR(20) NOT NULL,
+			c_street_2 VARCHAR(20) NOT NULL,
+			c_city VARCHAR(20) NOT NULL,
+			c_state CHAR(2) NOT NULL,
+			c_zip CHAR(9) NOT NULL,
+			c_phone CHAR(16) NOT NULL,
+			c_since ` + tsDef + ` NOT NULL,
+			c_credit CHAR(2) NOT NULL,
+			c_credit_lim DECIMAL(12,2) NOT NULL,
+			c_discount DECIMAL(4,4) NOT NULL,
+			c_balance DECIMAL(12,2) NOT NULL,
+			c_ytd_payment DECIMAL(12,2) NOT NULL,
+			c_payment_cnt INT NOT NULL,
+			c_delivery_cnt INT NOT NULL,
+			c_data VARCHAR(500) NOT NULL,
+			PRIMARY KEY (c_w_id, c_d_id, c_id)`,
+		"history": `h_c_id INT NOT NULL,
+			h_c_d_id INT NOT NULL,
+			h_c_w_id INT NOT NULL,
+			h_d_id INT NOT NULL,
+			h_w_id INT NOT NULL,
+			h_date ` + tsDef + ` NOT NULL,
+			h_amount DECIMAL(6,2) NOT NULL,
+			h_data VARCHAR(24) NOT NULL`,
+		"new_orders": `no_o_id INT NOT NULL,
+			no_d_id INT NOT NULL,
+			no_w_id INT NOT NULL,
+			PRIMARY KEY (no_w_id, no_d_id, no_o_id)`,
+		"orders": `o_id INT NOT NULL,
+			o_d_id INT NOT NULL,
+			o_w_id INT NOT NULL,
+			o_c_id INT NOT NULL,
+			o_entry_d ` + tsDef + ` NOT NULL,
+			o_carrier_id INT,
+			o_ol_cnt INT NOT NULL,
+			o_all_local INT NOT NULL,
+			PRIMARY KEY (o_w_id, o_d_id, o_id)`,
+		"order_line": `ol_o_id INT NOT NULL,
+			ol_d_id INT NOT NULL,
+			ol_w_id INT NOT NULL,
+			ol_number INT NOT NULL,
+			ol_i_id INT NOT NULL,
+			ol_supply_w_id INT NOT NULL,
+			ol_delivery_d ` + tsDef + ` NULL,
+			ol_quantity INT NOT NULL,
+			ol_amount DECIMAL(6,2) NOT NULL,
+			ol_dist_info CHAR(24) NOT NULL,
+			PRIMARY KEY (ol_w_id, ol_d_id, ol_o_id, ol_number)`,
+		"item": `i_id INT NOT NULL,
+			i_im_id INT NOT NULL,
+			i_name VARCHAR(24) NOT NULL,
+			i_price DECIMAL(5,2) NOT NULL,
+			i_data VARCHAR(50) NOT NULL,
+			PRIMARY KEY (i_id)`,
+		"stock": `s_i_id INT NOT NULL,
+			s_w_id INT NOT NULL,
+			s_quantity INT NOT NULL,
+			` + stockDists + `s_ytd INT NOT NULL,
+			s_order_cnt INT NOT NULL,
+			s_remote_cnt INT NOT NULL,
+			s_data VARCHAR(50) NOT NULL,
+			PRIMARY KEY (s_w_id, s_i_id)`,
+	}
+
+	for _, table := range tpccTables {
+		fmt.Printf("Creating table '%s'...\n", table)
+		_, err := t.db.ExecContext(ctx, fmt.Sprintf("CREATE TABLE %s (\n%s\n) %s %s", table, defs[table], engineDef, t.opts.CreateTableOpts))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 4.3.3.1 the item table
+func (t *TPCCBench) loadItems(ctx context.Context) error {
+	b := t.newBatch(ctx, "item", "i_id, i_im_id, i_name, i_price, i_data")
+	for i := 1; i <= tpccItems; i++ {
+		err := b.add(fmt.Sprintf("(%d, %d, '%s', %.2f, '%s')", i, sbRand(1, 10000), tpccAString(14, 24), float64(sbRand(100, 10000))/100, tpccData()))
+		if err != nil {
+			return err
+		}
+	}
+	return b.flush()
+}
+
+// 4.3.3.1 rows which belong to a warehouse
+func (t *TPCCBench) loadWarehouse(ctx context.Context, wId int) error {
+	_, err := t.db.ExecContext(ctx, fmt.Sprintf("INSERT INTO warehouse (w_id, w_name, w_street_1, w_street_2, w_city, w_state, w_zip, w_tax, w_ytd) VALUES (%d, %s, %.4f, 300000.00)",
+		wId, tpccAddress(6, 10), float64(sbRand(0, 2000))/10000))
+	if err != nil {
+		return err
+	}
+
+	stock := t.newBatch(ctx, "stock", "s_i_id, s_w_id, s_quantity, s_dist_01, s_dist_02, s_dist_03, s_dist_04, s_dist_05, s_dist_06, s_dist_07, s_dist_08, s_dist_09, s_dist_10, s_ytd, s_order_cnt, s_remote_cnt, s_data")
+	for i := 1; i <= tpccItems; i++ {
+		dists := make([]string, tpccDistrictsPerWH)
+		for d := range dists {
+			dists[d] = "'" + tpccAString(24, 24) + "'"
+		}
+		err = stock.add(fmt.Sprintf("(%d, %d, %d, %s, 0, 0, 0, '%s')", i, wId, sbRand(10, 100), strings.Join(dists, ", "), tpccData()))
+		if err != nil {
+			return err
+		}
+	}
+	err = stock.flush()
+	if err != nil {
+		return err
+	}
+
+	for dId := 1; dId <= tpccDistrictsPerWH; dId++ {
+		err = t.loadDistrict(ctx, wId, dId)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *TPCCBench) loadDistrict(ctx context.Context, wId int, dId int) error {
+	_, err := t.db.ExecContext(ctx, fmt.Sprintf("INSERT INTO district (d_id, d_w_id, d_name, d_street_1, d_street_2, d_city, d_state, d_zip, d_tax, d_ytd, d_next_o_id) VALUES (%d, %d, %s, %.4f, 30000.00, %d)",
+		dId, wId, tpccAddress(6, 10), float64(sbRand(0, 2000))/10000, tpccOrdersPerDistrict+1))
+	if err != nil {
+		return err
+	}
+
+	customer := t.newBatch(ctx, "customer", "c_id, c_d_id, c_w_id, c_first, c_middle, c_last, c_street_1, c_street_2, c_city, c_state, c_zip, c_phone, c_since, c_credit, c_credit_lim, c_discount, c_balance, c_ytd_payment, c_payment_cnt, c_delivery_cnt, c_data")
+	history := t.newBatch(ctx, "history", "h_c_id, h_c_d_id, h_c_w_id, h_d_id, h_w_id, h_date, h_amount, h_data")
+	for cId := 1; cId <= tpccCustomersPerDistrict; cId++ {
+		// the first 1000 customers cover all the last names
+		var cLast string
+		if cId <= 1000 {
+			cLast = tpccLastName(cId - 1)
+		} else {
+			cLast = tpccLastName(nuRand(255, tpccCLast, 0, 999))
+		}
+		credit := "GC"
+		if sbRand(1, 10) == 1 {
+			credit = "BC"
+		}
+
+		err = customer.add(fmt.Sprintf("(%d, %d, %d, '%s', 'OE', '%s', '%s', '%s', '%s', '%s', '%s', '%s', CURRENT_TIMESTAMP, '%s', 50000.00, %.4f, -10.00, 10.00, 1, 0, '%s')",
+			cId, dId, wId, tpccAString(8, 16), cLast, tpccAString(10, 20), tpccAString(10, 20), tpccAString(10, 20), tpccAString(2, 2), tpccZip(), tpccNString(16),
+			credit, float64(sbRand(0, 5000))/10000, tpccAString(300, 500)))
+		if err != nil {
+			return err
+		}
+		err = history.add(fmt.Sprintf("(%d, %d, %d, %d, %d, CURRENT_TIMESTAMP, 10.00, '%s')", cId, dId, wId, dId, wId, tpccAString(12, 24)))
+		if err != nil {
+			return err
+		}
+	}
+	err = customer.flush()
+	if err != nil {
+		return err
+	}
+	err = history.flush()
+	if err != nil {
+		return err
+	}
+
+	orders := t.newBatch(ctx, "orders", "o_id, o_d_id, o_w_id, o_c_id, o_entry_d, o_carrier_id, o_ol_cnt, o_all_local")
+	orderLines := t.newBatch(ctx, "order_line", "ol_o_id, ol_d_id, ol_w_id, ol_number, ol_i_id, ol_supply_w_id, ol_delivery_d, ol_quantity, ol_amount, ol_dist_info")
+	newOrders := t.newBatch(ctx, "new_orders", "no_o_id, no_d_id, no_w_id")
+	// o_c_id is a random permutation of customer ids
+	customerIds := rand.Perm(tpccCustomersPerDistrict)
+	for oId := 1; oId <= tpccOrdersPerDistrict; oId++ {
+		olCnt := sbRand(5, 15)
+		delivered := oId < tpccFirstNewOrder
+
+		carrierId := "NULL"
+		if delivered {
+			carrierId = strconv.Itoa(sbRand(1, 10))
+		}
+		err = orders.add(fmt.Sprintf("(%d, %d, %d, %d, CURRENT_TIMESTAMP, %s, %d, 1)", oId, dId, wId, customerIds[oId-1]+1, carrierId, olCnt))
+		if err != nil {
+			return err
+		}
+
+		for olNumber := 1; olNumber <= olCnt; olNumber++ {
+			deliveryD, amount := "CURRENT_TIMESTAMP", "0.00"
+			if !delivered {
+				deliveryD, amount = "NULL", fmt.Sprintf("%.2f", float64(sbRand(1, 999999))/100)
+			}
+			err = orderLines.add(fmt.Sprintf("(%d, %d, %d, %d, %d, %d, %s, 5, %s, '%s')", oId, dId, wId, olNumber, sbRand(1, tpccItems), wId, deliveryD, amount, tpccAString(24, 24)))
+			if err != nil {
+				return err
+			}
+		}
+
+		if !delivered {
+			err = newOrders.add(fmt.Sprintf("(%d, %d, %d)", oId, dId, wId))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, b := range []*tpccBatch{orders, orderLines, newOrders} {
+		err = b.flush()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tpccBatch inserts rows by multi-row INSERT statements like insertRows.
+type tpccBatch struct {
+	ctx     context.Context
+	db      *sql.DB
+	table   string
+	columns string
+	values  []string
+}
+
+func (t *TPCCBench) newBatch(ctx context.Context, table string, columns string) *tpccBatch {
+	return &tpccBatch{ctx: ctx, db: t.db, table: table, columns: columns}
+}
+
+func (b *tpccBatch) add(row string) error {
+	b.values = append(b.values, row)
+	if len(b.values) >= tpccInsertBatch {
+		return b.flush()
+	}
+	return nil
+}
+
+func (b *tpccBatch) flush() error {
+	if len(b.values) == 0 {
+		return nil
+	}
+	_, err := b.db.ExecContext(b.ctx, fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", b.table, b.columns, strings.Join(b.values, ",")))
+	b.values = b.values[:0]
+	return err
+}
+
+func (tx *tpccTx) queryRow(stmtName string, args ...any) *stmtRow {
+	tx.numReads++
+	return rowOf(tx.e.query(tx.ctx, tpccTableNum, stmtName, args...))
+}
+
+func (tx *tpccTx) query(stmtName string, args ...any) (*sql.Rows, error) {
+	tx.numReads++
+	return tx.e.query(tx.ctx, tpccTableNum, stmtName, args...)
+}
+
+// queryAll runs the query and discards the rows.
+func (tx *tpccTx) queryAll(stmtName string, args ...any) error {
+	return queryAll(tx.query(stmtName, args...))
+}
+
+// exec runs the write. Writes which change no rows are counted as other queries.
+func (tx *tpccTx) exec(stmtName string, args ...any) error {
+	changed, err := execCounted(tx.ctx, tx.e, tpccTableNum, stmtName, args...)
+	if err != nil {
+		return err
+	}
+	if changed {
+		tx.numWrites++
+	} else {
+		tx.numOthers++
+	}
+	return nil
+}
+
+// rebindPgSQL replaces ? placeholders with $1, $2, ...
+func rebindPgSQL(query string) string {
+	var sb strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+		} else {
+			sb.WriteRune(c)
+		}
+	}
+	return sb.String()
+}
+
+// nuRand is the non-uniform random function of 2.1.6.
+func nuRand(a int, c int, x int, y int) int {
+	return (((sbRand(0, a) | sbRand(x, y)) + c) % (y - x + 1)) + x
+}
+
+// tpccLastName builds C_LAST from the three digits of num by 4.3.2.3.
+func tpccLastName(num int) string {
+	return tpccSyllables[num/100] + tpccSyllables[(num/10)%10] + tpccSyllables[num%10]
+}
+
+// tpccAString is a random a-string of 4.3.2.2. Only alphanumerics are used so that no escape is needed in SQL.
+func tpccAString(minLen int, maxLen int) string {
+	const chars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+	buf := make([]byte, sbRand(minLen, maxLen))
+	for i := range buf {
+		buf[i] = chars[rand.Intn(len(chars))]
+	}
+	return string(buf)
+}
+
+// tpccNString is a random n-string of 4.3.2.2.
+func tpccNString(length int) string {
+	return sbRandStr(strings.Repeat("#", length))
+}
+
+func tpccZip() string {
+	return tpccNString(4) + "11111"
+}
+
+// tpccData is I_DATA or S_DATA, which contains "ORIGINAL" in 10% of the rows.
+func tpccData() string {
+	data := tpccAString(26, 50)
+	if sbRand(1, 10) == 1 {
+		pos := sbRand(0, len(data)-8)
+		data = data[:pos] + "ORIGINAL" + data[pos+8:]
+	}
+	return data
+}
+
+// tpccAddress returns the quoted values of name, street_1, street_2, city, state and zip.
+func tpccAddress(minName int, maxName int) string {
+	return fmt.Sprintf("'%s', '%s', '%s', '%s', '%s', '%s'", tpccAString(minName, maxName), tpccAString(10, 20), tpccAString(10, 20), tpccAString(10, 20), tpccAString(2, 2), tpccZip())
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestTPCCPickTransaction(t *testing.T) {
+	counts := make(map[string]int)
+	for n := 1; n <= 100; n++ {
+		counts[tpccPickTransaction(n)]++
+	}
+
+	expected := map[string]int{tpccNewOrder: 45, tpccPayment: 43, tpccOrderStatus: 4, tpccDelivery: 4, tpccStockLevel: 4}
+	for name, count := range expected {
+		if counts[name] != count {
+			t.Errorf("Expected %d%% of %s, got %d%%", count, name, counts[name])
+		}
+	}
+}
+
+func TestNURand(t *testing.T) {
+	for i := 0; i < 10000; i++ {
+		if n := nuRand(1023, tpccCId, 1, tpccCustomersPerDistrict); n < 1 || n > tpccCustomersPerDistrict {
+			t.Fatalf("Expected customer id from 1 to %d, got %d", tpccCustomersPerDistrict, n)
+		}
+	}
+}
+
+func TestTPCCLastName(t *testing.T) {
+	expected := map[int]string{0: "BARBARBAR", 371: "PRICALLYOUGHT", 999: "EINGEINGEING"}
+	for num, name := range expected {
+		if actual := tpccLastName(num); actual != name {
+			t.Errorf("Expected %s for %d, got %s", name, num, actual)
+		}
+	}
+}
+
+func TestRebindPgSQL(t *testing.T) {
+	query := rebindPgSQL("UPDATE stock SET s_quantity=? WHERE s_i_id=? AND s_w_id=?")
+	expected := "UPDATE stock SET s_quantity=$1 WHERE s_i_id=$2 AND s_w_id=$3"
+	if query != expected {
+		t.Errorf("Expected %s, got %s", expected, query)
+	}
+}
@@ -33,6 +33,11 @@ func NewHistogram(size int, rangeMin, rangeMax float64) *Histogram {
 	}
 }
 
+// NewLatencyHistogram returns a histogram of latencies in milliseconds with the same range as Runner uses.
+func NewLatencyHistogram() *Histogram {
+	return NewHistogram(histogramSize, histogramMin, histogramMax)
+}
+
 func (h *Histogram) Add(value float64) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -3,6 +3,7 @@ package sysbench
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"math"
 	"os"
@@ -22,6 +23,10 @@ const (
 	nano2sec  = 1000000000.0
 )
 
+// ErrNoMoreEvents is returned by Event() when the benchmark has completed its work, such as the total size of memory benchmark.
+// The event which returns it is not counted, and the thread stops. Run() finishes when all the threads stop.
+var ErrNoMoreEvents = errors.New("no more events")
+
 type (
 	Benchmark interface {
 		// when Runner.Prepare(), Runner.Run() is called, Init() is called once in advance.
@@ -36,6 +41,48 @@ type (
 		Event(context.Context) (numReads, numWrites, numOthers, numIgnoredErros uint64, err error)
 	}
 
+	// Reporter is an optional interface for benchmarks which print their own statistics.
+	Reporter interface {
+		// when Runner.Run() is called, Report() is called once after the statistics are printed.
+		Report(*Result)
+	}
+
+	// Summarizer is an optional interface for benchmarks which are not measured by SQL statistics.
+	Summarizer interface {
+		// when Runner.Run() is called, Summary() is called once in place of printing the SQL statistics.
+		Summary(*Result)
+	}
+
+	// Result is the outcome of Runner.Run() passed to Reporter.
+	Result struct {
+		TotalTime   time.Duration
+		TotalEvents uint64
+		TotalReads  uint64
+		TotalWrites uint64
+		TotalOthers uint64
+		Percentile  int
+	}
+
+	// Monitor is an optional interface for benchmarks which measure something in background during the events.
+	Monitor interface {
+		// when Runner.Run() is called, Monitor() is called in a goroutine after PreEvent(). It should return when the context is done.
+		Monitor(context.Context)
+		// when intermediate results are reported, IntervalReport() is called and the returned columns are appended to the line.
+		IntervalReport() string
+	}
+
+	// Checker is an optional interface for benchmarks which support the check command.
+	Checker interface {
+		// when Runner.Check() is called, Check() is called once.
+		Check(context.Context) error
+	}
+
+	// Cleaner is an optional interface for benchmarks which support the cleanup command.
+	Cleaner interface {
+		// when Runner.Cleanup() is called, Cleanup() is called once.
+		Cleanup(context.Context) error
+	}
+
 	RunnerOpts struct {
 		Threads        int    `long:"threads" description:"number of threads to use" default:"1"`
 		Events         uint64 `long:"events" description:"limit for total number of events" default:"0"`
@@ -53,8 +100,16 @@ type (
 	benchmarkAdapter struct {
 		bench Benchmark
 	}
+
+	threadIDKey struct{}
 )
 
+// ThreadID returns the number of the thread (0-origin) which calls Event() with the context.
+func ThreadID(ctx context.Context) int {
+	id, _ := ctx.Value(threadIDKey{}).(int)
+	return id
+}
+
 func (a *benchmarkAdapter) Init(ctx context.Context) error {
 	return a.bench.Init(ctx)
 }
@@ -75,6 +130,50 @@ func (a *benchmarkAdapter) Event(ctx context.Context) (uint64, uint64, uint64, u
 	return a.bench.Event(ctx)
 }
 
+func (a *benchmarkAdapter) Check(ctx context.Context) error {
+	c, ok := a.bench.(Checker)
+	if !ok {
+		return fmt.Errorf("check is not supported by this benchmark")
+	}
+	return c.Check(ctx)
+}
+
+func (a *benchmarkAdapter) Cleanup(ctx context.Context) error {
+	c, ok := a.bench.(Cleaner)
+	if !ok {
+		return fmt.Errorf("cleanup is not supported by this benchmark")
+	}
+	return c.Cleanup(ctx)
+}
+
+func (a *benchmarkAdapter) Monitor(ctx context.Context) {
+	if m, ok := a.bench.(Monitor); ok {
+		m.Monitor(ctx)
+	}
+}
+
+func (a *benchmarkAdapter) IntervalReport() string {
+	if m, ok := a.bench.(Monitor); ok {
+		return m.IntervalReport()
+	}
+	return ""
+}
+
+// Summary prints the summary of the benchmark, and returns false if the benchmark does not have its own.
+func (a *benchmarkAdapter) Summary(result *Result) bool {
+	if s, ok := a.bench.(Summarizer); ok {
+		s.Summary(result)
+		return true
+	}
+	return false
+}
+
+func (a *benchmarkAdapter) Report(result *Result) {
+	if r, ok := a.bench.(Reporter); ok {
+		r.Report(result)
+	}
+}
+
 func NewRunner(option *RunnerOpts, bench Benchmark) *Runner {
 	return &Runner{option, &benchmarkAdapter{bench}}
 }
@@ -100,6 +199,40 @@ func (r *Runner) Prepare() error {
 	return nil
 }
 
+func (r *Runner) Check() error {
+	ctx := context.Background()
+
+	err := r.bench.Init(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = r.bench.Check(ctx)
+	if err != nil {
+		_ = r.bench.Done()
+		return err
+	}
+
+	return r.bench.Done()
+}
+
+func (r *Runner) Cleanup() error {
+	ctx := context.Background()
+
+	err := r.bench.Init(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = r.bench.Cleanup(ctx)
+	if err != nil {
+		_ = r.bench.Done()
+		return err
+	}
+
+	return r.bench.Done()
+}
+
 func (r *Runner) Run() error {
 	// global shared stats
 	var totalQueries, totalTransactions atomic.Uint64
@@ -149,6 +282,14 @@ func (r *Runner) Run() error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(r.opts.Time)*time.Second)
 	defer cancel()
 
+	// goroutine for background measurement by the benchmark
+	var monitorWg sync.WaitGroup
+	monitorWg.Add(1)
+	go func() {
+		defer monitorWg.Done()
+		r.bench.Monitor(ctx)
+	}()
+
 	// goroutine for reporting
 	if r.opts.ReportInterval > 0 {
 		go func() {
@@ -171,7 +312,7 @@ func (r *Runner) Run() error {
 					deltaOthers := totalOthers.Load() - lastOthers
 					deltaIgnoredErrors := totalIgnoredErrors.Load() - lastIgnoredErrors
 
-					fmt.Printf("[ %.0fs ] thds: %d tps: %4.2f qps: %4.2f (r/w/o: %4.2f/%4.2f/%4.2f) lat (ms,%d%%): %4.2f err/s %4.2f reconn/s: N/A\n",
+					fmt.Printf("[ %.0fs ] thds: %d tps: %4.2f qps: %4.2f (r/w/o: %4.2f/%4.2f/%4.2f) lat (ms,%d%%): %4.2f err/s %4.2f reconn/s: N/A%s\n",
 						time.Since(begin).Seconds(),
 						r.opts.Threads,
 						float64(deltaTransactions)/intervalf,
@@ -181,7 +322,8 @@ func (r *Runner) Run() error {
 						float64(deltaOthers)/intervalf,
 						percentile,
 						intervalHistogram.GetPercentileAndReset(percentile), // percentile
-						float64(deltaIgnoredErrors)/intervalf)
+						float64(deltaIgnoredErrors)/intervalf,
+						r.bench.IntervalReport())
 
 					lastQueries = totalQueries.Load()
 					lastTransactions = totalTransactions.Load()
@@ -203,6 +345,7 @@ func (r *Runner) Run() error {
 
 			//var pe error = nil
 			var eventBegin time.Time
+			threadCtx := context.WithValue(ctx, threadIDKey{}, i)
 
 			for {
 				select {
@@ -216,7 +359,10 @@ func (r *Runner) Run() error {
 					}
 
 					eventBegin = time.Now()
-					reads, writes, others, igerrs, err := r.bench.Event(ctx)
+					reads, writes, others, igerrs, err := r.bench.Event(threadCtx)
+					if err == ErrNoMoreEvents {
+						return
+					}
 					if err != nil && err != context.DeadlineExceeded && err != context.Canceled && err != sql.ErrTxDone {
 						fmt.Println(err)
 						cancel()
@@ -270,6 +416,12 @@ func (r *Runner) Run() error {
 		}()
 	}
 
+	// finish when all the threads stop before --time
+	go func() {
+		wg.Wait()
+		cancel()
+	}()
+
 	// signal handler
 	sigchan := make(chan os.Signal, 1)
 	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
@@ -282,7 +434,11 @@ func (r *Runner) Run() error {
 	}
 
 	wg.Wait()
-	totalTime := time.Since(begin).Seconds()
+	totalDuration := time.Since(begin)
+	totalTime := totalDuration.Seconds()
+
+	cancel()
+	monitorWg.Wait()
 
 	err = r.bench.Done()
 	if err != nil {
@@ -295,19 +451,30 @@ func (r *Runner) Run() error {
 		fmt.Println("")
 	}
 
-	fmt.Printf("SQL statistics:\n"+
-		"    queries performed:\n"+
-		"        read:                            %d\n"+
-		"        write:                           %d\n"+
-		"        other:                           %d\n"+
-		"        total:                           %d\n"+
-		"    transactions:                        %-6d (%.2f per sec.)\n"+
-		"    queries:                             %-6d (%.2f per sec.)\n"+
-		"    ignored errors:                      %-6d (%.2f per sec.)\n"+
-		"    reconnects:                          N/A    (N/A per sec.)\n\n",
-		totalReads.Load(), totalWrites.Load(), totalOthers.Load(), (totalReads.Load() + totalWrites.Load() + totalOthers.Load()),
-		totalTransactions.Load(), float64(totalTransactions.Load())/float64(totalTime), totalQueries.Load(), float64(totalQueries.Load())/float64(totalTime),
-		totalIgnoredErrors.Load(), float64(totalIgnoredErrors.Load())/float64(totalTime))
+	result := &Result{
+		TotalTime:   totalDuration,
+		TotalEvents: totalTransactions.Load(),
+		TotalReads:  totalReads.Load(),
+		TotalWrites: totalWrites.Load(),
+		TotalOthers: totalOthers.Load(),
+		Percentile:  percentile,
+	}
+
+	if !r.bench.Summary(result) {
+		fmt.Printf("SQL statistics:\n"+
+			"    queries performed:\n"+
+			"        read:                            %d\n"+
+			"        write:                           %d\n"+
+			"        other:                           %d\n"+
+			"        total:                           %d\n"+
+			"    transactions:                        %-6d (%.2f per sec.)\n"+
+			"    queries:                             %-6d (%.2f per sec.)\n"+
+			"    ignored errors:                      %-6d (%.2f per sec.)\n"+
+			"    reconnects:                          N/A    (N/A per sec.)\n\n",
+			totalReads.Load(), totalWrites.Load(), totalOthers.Load(), (totalReads.Load() + totalWrites.Load() + totalOthers.Load()),
+			totalTransactions.Load(), float64(totalTransactions.Load())/float64(totalTime), totalQueries.Load(), float64(totalQueries.Load())/float64(totalTime),
+			totalIgnoredErrors.Load(), float64(totalIgnoredErrors.Load())/float64(totalTime))
+	}
 
 	fmt.Printf("General statistics:\n"+
 		"    total time:                          %.4fs\n"+
@@ -346,5 +513,7 @@ func (r *Runner) Run() error {
 		"    events (avg/stddev):           %.4f/%3.2f\n"+
 		"    execution time (avg/stddev):   %.4f/%3.2f\n", transactionsAvg, transactionsStddev, float64(latencyNanoAvg)/nano2sec, float64(latencyNanoStddev)/nano2sec)
 
+	r.bench.Report(result)
+
 	return nil
 }